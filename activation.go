@@ -20,6 +20,10 @@ func (r *Relu) Backward(input float64) float64 {
 	return 0
 }
 
+func (r *Relu) Spec() string {
+	return "relu"
+}
+
 type LeakyRelu struct {
 	Alpha float64
 }
@@ -38,4 +42,284 @@ func (r *LeakyRelu) Backward(input float64) float64 {
 	return r.Alpha
 }
 
+func (r *LeakyRelu) Spec() string {
+	return FormatSpec("leaky_relu", Params{"alpha": r.Alpha})
+}
+
+// sigmoid is computed so it does not overflow for large negative inputs
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1 + e)
+}
+
+// softplus is computed so it does not overflow for large inputs
+func softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+type Sigmoid struct{}
+
+func (r *Sigmoid) Forward(input float64) float64 {
+	return sigmoid(input)
+}
+
+func (r *Sigmoid) Backward(input float64) float64 {
+	s := sigmoid(input)
+	return s * (1 - s)
+}
+
+func (r *Sigmoid) Spec() string {
+	return "sigmoid"
+}
+
+type Tanh struct{}
+
+func (r *Tanh) Forward(input float64) float64 {
+	return math.Tanh(input)
+}
+
+func (r *Tanh) Backward(input float64) float64 {
+	t := math.Tanh(input)
+	return 1 - t*t
+}
+
+func (r *Tanh) Spec() string {
+	return "tanh"
+}
+
+// ELU is the exponential linear unit, Alpha scales the negative side
+type ELU struct {
+	Alpha float64
+}
+
+func (r *ELU) Forward(input float64) float64 {
+	if input > 0 {
+		return input
+	}
+	return r.Alpha * math.Expm1(input)
+}
+
+func (r *ELU) Backward(input float64) float64 {
+	if input > 0 {
+		return 1
+	}
+	return r.Alpha * math.Exp(input)
+}
+
+func (r *ELU) Spec() string {
+	return FormatSpec("elu", Params{"alpha": r.Alpha})
+}
+
+// SELU is the scaled exponential linear unit with the constants of the self-normalizing network paper
+type SELU struct{}
+
+const (
+	seluAlpha = 1.6732632423543772848170429916717
+	seluScale = 1.0507009873554804934193349852946
+)
+
+func (r *SELU) Forward(input float64) float64 {
+	if input > 0 {
+		return seluScale * input
+	}
+	return seluScale * seluAlpha * math.Expm1(input)
+}
+
+func (r *SELU) Backward(input float64) float64 {
+	if input > 0 {
+		return seluScale
+	}
+	return seluScale * seluAlpha * math.Exp(input)
+}
+
+func (r *SELU) Spec() string {
+	return "selu"
+}
+
+// GELU is the exact gaussian error linear unit x * Φ(x)
+type GELU struct{}
+
+func (r *GELU) Forward(input float64) float64 {
+	return input * 0.5 * (1 + math.Erf(input/math.Sqrt2))
+}
+
+func (r *GELU) Backward(input float64) float64 {
+	cdf := 0.5 * (1 + math.Erf(input/math.Sqrt2))
+	pdf := math.Exp(-input*input/2) / math.Sqrt(2*math.Pi)
+	return cdf + input*pdf
+}
+
+func (r *GELU) Spec() string {
+	return "gelu"
+}
+
+// Swish is x * sigmoid(Beta * x), a Beta of 1 gives SiLU
+type Swish struct {
+	Beta float64
+}
+
+func (r *Swish) Forward(input float64) float64 {
+	return input * sigmoid(r.Beta*input)
+}
+
+func (r *Swish) Backward(input float64) float64 {
+	s := sigmoid(r.Beta * input)
+	return s + r.Beta*input*s*(1-s)
+}
+
+func (r *Swish) Spec() string {
+	return FormatSpec("swish", Params{"beta": r.Beta})
+}
+
+type Softplus struct{}
+
+func (r *Softplus) Forward(input float64) float64 {
+	return softplus(input)
+}
+
+func (r *Softplus) Backward(input float64) float64 {
+	return sigmoid(input)
+}
+
+func (r *Softplus) Spec() string {
+	return "softplus"
+}
+
+// HardSigmoid is the piecewise linear approximation x/6 + 1/2 clipped to [0, 1]
+type HardSigmoid struct{}
+
+func (r *HardSigmoid) Forward(input float64) float64 {
+	return math.Min(math.Max(input/6+0.5, 0), 1)
+}
+
+func (r *HardSigmoid) Backward(input float64) float64 {
+	if input > -3 && input < 3 {
+		return 1.0 / 6
+	}
+	return 0
+}
+
+func (r *HardSigmoid) Spec() string {
+	return "hard_sigmoid"
+}
+
+type Identity struct{}
+
+func (r *Identity) Forward(input float64) float64 {
+	return input
+}
+
+func (r *Identity) Backward(input float64) float64 {
+	return 1
+}
+
+func (r *Identity) Spec() string {
+	return "identity"
+}
+
+// Mish is x * tanh(softplus(x))
+type Mish struct{}
+
+func (r *Mish) Forward(input float64) float64 {
+	return input * math.Tanh(softplus(input))
+}
+
+func (r *Mish) Backward(input float64) float64 {
+	t := math.Tanh(softplus(input))
+	return t + input*(1-t*t)*sigmoid(input)
+}
+
+func (r *Mish) Spec() string {
+	return "mish"
+}
+
+// LearnableActivator is an Activator with parameters that are trained along with the weights and bias
+type LearnableActivator interface {
+	Activator
+	// Clone copies the activator, every neuron trains its own copy
+	Clone() LearnableActivator
+	Parameters() []float64
+	SetParameters(p []float64)
+	// ParameterGradients returns the derivative of Forward(input) with respect to each parameter
+	ParameterGradients(input float64) []float64
+}
+
+// PReLU is a LeakyRelu that learns Alpha
+type PReLU struct {
+	Alpha float64
+}
+
+func (r *PReLU) Forward(input float64) float64 {
+	if input < 0 {
+		return r.Alpha * input
+	}
+	return input
+}
+
+func (r *PReLU) Backward(input float64) float64 {
+	if input >= 0 {
+		return 1
+	}
+	return r.Alpha
+}
+
+func (r *PReLU) Clone() LearnableActivator {
+	return &PReLU{Alpha: r.Alpha}
+}
+
+func (r *PReLU) Parameters() []float64 {
+	return []float64{r.Alpha}
+}
+
+func (r *PReLU) SetParameters(p []float64) {
+	r.Alpha = p[0]
+}
+
+func (r *PReLU) ParameterGradients(input float64) []float64 {
+	if input < 0 {
+		return []float64{input}
+	}
+	return []float64{0}
+}
+
+func (r *PReLU) Spec() string {
+	return FormatSpec("prelu", Params{"alpha": r.Alpha})
+}
 
+// PSwish is a Swish that learns Beta
+type PSwish struct {
+	Beta float64
+}
+
+func (r *PSwish) Forward(input float64) float64 {
+	return input * sigmoid(r.Beta*input)
+}
+
+func (r *PSwish) Backward(input float64) float64 {
+	s := sigmoid(r.Beta * input)
+	return s + r.Beta*input*s*(1-s)
+}
+
+func (r *PSwish) Clone() LearnableActivator {
+	return &PSwish{Beta: r.Beta}
+}
+
+func (r *PSwish) Parameters() []float64 {
+	return []float64{r.Beta}
+}
+
+func (r *PSwish) SetParameters(p []float64) {
+	r.Beta = p[0]
+}
+
+func (r *PSwish) ParameterGradients(input float64) []float64 {
+	s := sigmoid(r.Beta * input)
+	return []float64{input * input * s * (1 - s)}
+}
+
+func (r *PSwish) Spec() string {
+	return FormatSpec("pswish", Params{"beta": r.Beta})
+}
@@ -0,0 +1,158 @@
+package neuron
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// activators lists one of each built-in activator
+var activators = []Activator{
+	&Relu{},
+	&LeakyRelu{0.1},
+	&Sigmoid{},
+	&Tanh{},
+	&ELU{Alpha: 1.5},
+	&SELU{},
+	&GELU{},
+	&Swish{Beta: 1},
+	&Swish{Beta: 2.5},
+	&Softplus{},
+	&HardSigmoid{},
+	&Identity{},
+	&Mish{},
+	&PReLU{Alpha: 0.3},
+	&PSwish{Beta: 1.5},
+}
+
+func TestActivatorGradients(t *testing.T) {
+	const h = 1e-6
+	// stay clear of the kinks at 0 and ±3
+	inputs := []float64{-30, -5, -2.5, -1, -0.3, 0.2, 0.7, 1.5, 2.9, 4, 30}
+
+	for _, a := range activators {
+		spec, _ := SpecOf(a)
+		for _, x := range inputs {
+			numerical := (a.Forward(x+h) - a.Forward(x-h)) / (2 * h)
+			if got := a.Backward(x); math.Abs(got-numerical) > 1e-5 {
+				t.Errorf("%s: derivative at %.1f is %.06f, numerically %.06f", spec, x, got, numerical)
+			}
+			if y := a.Forward(x); math.IsNaN(y) || math.IsInf(y, 0) {
+				t.Errorf("%s: forward at %.1f is %v", spec, x, y)
+			}
+		}
+	}
+}
+
+func TestActivatorSpecs(t *testing.T) {
+	for _, a := range activators {
+		spec, err := SpecOf(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewActivator(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range []float64{-2, 0.5, 3} {
+			if a.Forward(x) != b.Forward(x) {
+				t.Errorf("%s: created from its spec gives %.06f at %.1f want %.06f", spec, b.Forward(x), x, a.Forward(x))
+			}
+		}
+	}
+}
+
+func TestLearnableActivators(t *testing.T) {
+	const h = 1e-6
+	for _, la := range []LearnableActivator{&PReLU{Alpha: 0.3}, &PSwish{Beta: 1.5}} {
+		spec, _ := SpecOf(la)
+		for _, x := range []float64{-3, -0.5, 0.4, 2} {
+			grads := la.ParameterGradients(x)
+			for k, p := range la.Parameters() {
+				up, down := la.Clone(), la.Clone()
+				ps := up.Parameters()
+				ps[k] = p + h
+				up.SetParameters(ps)
+				ps = down.Parameters()
+				ps[k] = p - h
+				down.SetParameters(ps)
+				numerical := (up.Forward(x) - down.Forward(x)) / (2 * h)
+				if math.Abs(grads[k]-numerical) > 1e-5 {
+					t.Errorf("%s: parameter %d gradient at %.1f is %.06f, numerically %.06f", spec, k, x, grads[k], numerical)
+				}
+			}
+		}
+	}
+
+	// every neuron learns its own alpha
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &PReLU{Alpha: 0.25},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.01)
+	input, err := NewInputLayer("input", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 4)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", &Config{
+		Precision:    0.0001,
+		Activator:    &Identity{},
+		PreProcessor: &SumPreProcessor{},
+	}, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+
+	dataset := make([]Sample, 50)
+	for i := range dataset {
+		x := rand.Float64()*4 - 2
+		dataset[i] = Sample{X: []float64{x}, Y: []float64{math.Abs(x)}}
+	}
+	if _, err := NewTrainer(sess, input, output).Fit(dataset, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if alpha := conf.Activator.(*PReLU).Alpha; alpha != 0.25 {
+		t.Errorf("the configured activator was trained, alpha is %.4f", alpha)
+	}
+	moved := 0
+	for _, n := range hidden.Neurons {
+		if n.Activator() == conf.Activator {
+			t.Errorf("neuron %d shares the configured activator", n.id)
+		}
+		if n.Activator().(*PReLU).Alpha != 0.25 {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("no neuron learned its alpha")
+	}
+
+	// the learned alphas are saved with the network
+	var buf bytes.Buffer
+	if err := Save(&buf, FORMAT_JSON, net); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf, FORMAT_JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range loaded.Layers[0].Neurons {
+		want := hidden.Neurons[i].Activator().(*PReLU).Alpha
+		if got := n.Activator().(*PReLU).Alpha; got != want {
+			t.Errorf("neuron %d loaded alpha %.4f want %.4f", n.id, got, want)
+		}
+	}
+}
@@ -1,26 +1,236 @@
 package neuron
 
-import "sync"
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DEFAULT_CACHE_SIZE is the number of forward passes a neuron keeps for their backward pass by default
+const DEFAULT_CACHE_SIZE = 1024
 
 type NeuronCache struct {
-	values [][]float64 // values cache
-	mu     sync.RWMutex
+	entries []cacheEntry // forward passes in the order they happened
+	cached  int          // number of entries with values
+	size    int          // maximum number of values, unbounded if 0
+	grads   []float64    // sum of the gradients of the current batch
+	count   int          // number of gradients in grads
+	mu      sync.RWMutex
+}
+
+// cacheEntry is the values of a forward pass, or a run of forward passes that were not cached
+type cacheEntry struct {
+	values  []float64
+	skipped int
 }
 
 func (pc *NeuronCache) Get() [][]float64 {
 	pc.mu.RLock()
 	defer pc.mu.RUnlock()
-	return pc.values
+	values := make([][]float64, 0, pc.cached)
+	for _, e := range pc.entries {
+		if e.skipped == 0 {
+			values = append(values, e.values)
+		}
+	}
+	return values
+}
+
+// Add adds values to the cache, if the cache is full they are not cached and only their place is kept,
+// so the backward passes still find the values of their own forward pass
+// it returns the number of values that were not cached
+func (pc *NeuronCache) Add(v ...float64) int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.size <= 0 || pc.cached < pc.size {
+		pc.entries = append(pc.entries, cacheEntry{values: v})
+		pc.cached++
+		return 0
+	}
+	if last := len(pc.entries) - 1; last >= 0 && pc.entries[last].skipped > 0 {
+		pc.entries[last].skipped++
+	} else {
+		pc.entries = append(pc.entries, cacheEntry{skipped: 1})
+	}
+	return 1
+}
+
+// Pop removes and returns the oldest values in the cache, false if there are none or they were not cached
+func (pc *NeuronCache) Pop() ([]float64, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if len(pc.entries) == 0 {
+		return nil, false
+	}
+	if pc.entries[0].skipped > 0 {
+		if pc.entries[0].skipped--; pc.entries[0].skipped == 0 {
+			pc.entries = pc.entries[1:]
+		}
+		return nil, false
+	}
+	v := pc.entries[0].values
+	pc.entries = pc.entries[1:]
+	pc.cached--
+	return v, true
 }
 
-func (pc *NeuronCache) Add(v ...float64) {
+// Accumulate adds the gradients of a sample to the batch and returns the number of samples in the batch
+func (pc *NeuronCache) Accumulate(grads ...float64) int {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
-	pc.values = append(pc.values, v)
+	if pc.grads == nil {
+		pc.grads = make([]float64, len(grads))
+	}
+	for i, g := range grads {
+		pc.grads[i] += g
+	}
+	pc.count++
+	return pc.count
+}
+
+// Gradients removes and returns the summed gradients of the batch and the number of samples in it
+func (pc *NeuronCache) Gradients() ([]float64, int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	grads, count := pc.grads, pc.count
+	pc.grads, pc.count = nil, 0
+	return grads, count
+}
+
+// PopLast removes and returns the newest values in the cache, false if there are none or they were not cached
+func (pc *NeuronCache) PopLast() ([]float64, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	last := len(pc.entries) - 1
+	if last < 0 {
+		return nil, false
+	}
+	if pc.entries[last].skipped > 0 {
+		if pc.entries[last].skipped--; pc.entries[last].skipped == 0 {
+			pc.entries = pc.entries[:last]
+		}
+		return nil, false
+	}
+	v := pc.entries[last].values
+	pc.entries = pc.entries[:last]
+	pc.cached--
+	return v, true
+}
+
+// Clear removes every forward pass, the gradients of the batch are kept
+func (pc *NeuronCache) Clear() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.entries, pc.cached = nil, 0
 }
 
 func (pc *NeuronCache) Zero() {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
-	pc.values = [][]float64{}
-}
\ No newline at end of file
+	pc.entries, pc.cached = nil, 0
+	pc.grads, pc.count = nil, 0
+}
+
+const (
+	METRIC_LOSS          = "loss"          // loss passed to Session.NextEpoch
+	METRIC_STEP_LOSS     = "step_loss"     // loss passed to Session.NextStep
+	METRIC_TRAIN_LOSS    = "train_loss"    // training loss, recorded by a Trainer when it validates
+	METRIC_LEARNING_RATE = "learning_rate" // learning rate in use during the epoch
+	METRIC_THROUGHPUT    = "throughput"    // steps per second during the epoch
+)
+
+// Metric is a single recorded value of a metric
+type Metric struct {
+	Time  time.Time `json:"time"`
+	Epoch int       `json:"epoch"`
+	Step  int       `json:"step"`
+	Value float64   `json:"value"`
+}
+
+// SessionCache keeps the history of every metric recorded by a Session
+type SessionCache struct {
+	series map[string][]Metric
+	mu     sync.RWMutex
+}
+
+func NewSessionCache() *SessionCache {
+	return &SessionCache{
+		series: map[string][]Metric{},
+		mu:     sync.RWMutex{},
+	}
+}
+
+func (sc *SessionCache) Add(name string, epoch, step int, value float64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.series[name] = append(sc.series[name], Metric{
+		Time:  time.Now(),
+		Epoch: epoch,
+		Step:  step,
+		Value: value,
+	})
+}
+
+// Names returns the names of all recorded metrics in alphabetical order
+func (sc *SessionCache) Names() []string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	names := make([]string, 0, len(sc.series))
+	for name := range sc.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Series returns a copy of the history of a metric in the order it was recorded
+func (sc *SessionCache) Series(name string) []Metric {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return append([]Metric{}, sc.series[name]...)
+}
+
+// Values returns only the values of a metric in the order they were recorded
+func (sc *SessionCache) Values(name string) []float64 {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	values := make([]float64, len(sc.series[name]))
+	for i, m := range sc.series[name] {
+		values[i] = m.Value
+	}
+	return values
+}
+
+// WriteCSV writes every recorded value as a row of metric, epoch, step, time and value
+func (sc *SessionCache) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"metric", "epoch", "step", "time", "value"}); err != nil {
+		return err
+	}
+	for _, name := range sc.Names() {
+		for _, m := range sc.Series(name) {
+			if err := cw.Write([]string{
+				name,
+				strconv.Itoa(m.Epoch),
+				strconv.Itoa(m.Step),
+				m.Time.Format(time.RFC3339Nano),
+				strconv.FormatFloat(m.Value, 'g', -1, 64),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes an object that maps the name of every metric to its history
+func (sc *SessionCache) WriteJSON(w io.Writer) error {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return json.NewEncoder(w).Encode(sc.series)
+}
@@ -0,0 +1,52 @@
+package neuron
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNeuronCacheKeepsOrder(t *testing.T) {
+	pc := &NeuronCache{size: 2}
+	for i := 0; i < 5; i++ {
+		if dropped := pc.Add(float64(i)); dropped != 0 && i < 2 || dropped != 1 && i >= 2 {
+			t.Errorf("pass %d: got %d not cached", i, dropped)
+		}
+	}
+	// the passes that did not fit keep their place, so every backward pass gets the values of its own forward pass
+	var got [][]float64
+	for i := 0; i < 5; i++ {
+		v, _ := pc.Pop()
+		got = append(got, v)
+	}
+	if want := [][]float64{{0}, {1}, nil, nil, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if _, ok := pc.Pop(); ok {
+		t.Errorf("expected the cache to be empty")
+	}
+
+	// space freed by a backward pass is used by the next forward pass
+	pc.Add(0)
+	pc.Add(1)
+	pc.Add(2)
+	if v, ok := pc.Pop(); !ok || v[0] != 0 {
+		t.Errorf("got %v want [0]", v)
+	}
+	pc.Add(3)
+	want := [][]float64{{1}, nil, {3}}
+	for _, w := range want {
+		if v, _ := pc.Pop(); !reflect.DeepEqual(v, w) {
+			t.Errorf("got %v want %v", v, w)
+		}
+	}
+
+	pc.Add(4)
+	pc.Add(5)
+	pc.Add(6)
+	if v, ok := pc.PopLast(); ok {
+		t.Errorf("got %v, expected the newest pass to be not cached", v)
+	}
+	if v, _ := pc.PopLast(); !reflect.DeepEqual(v, []float64{5}) {
+		t.Errorf("got %v want [5]", v)
+	}
+}
@@ -0,0 +1,246 @@
+package neuron
+
+import "fmt"
+
+// gateConfig is conf with act and a SumPreProcessor, for the trained neurons of a cell
+func gateConfig(conf *Config, act Activator) *Config {
+	gate := *conf
+	gate.Activator = act
+	gate.PreProcessor = &SumPreProcessor{}
+	return &gate
+}
+
+// LSTMLayer is a layer of long short-term memory cells built from neurons
+// the gates are trained, the neurons that combine them are frozen
+type LSTMLayer struct {
+	Name       string
+	Sess       *Session
+	InputGate  *Layer // sigmoid of the inputs and the hidden state
+	ForgetGate *Layer // sigmoid of the inputs and the hidden state
+	OutputGate *Layer // sigmoid of the inputs and the hidden state
+	Candidate  *Layer // tanh of the inputs and the hidden state
+	Cell       *Layer // forget * previous cell + input * candidate
+	Hidden     *Layer // output * tanh(cell), the output of the layer
+	layers     []*Layer
+}
+
+// NewLSTMLayer creates a layer of units LSTM cells, the gates are trained with conf
+// connect the layers before it with ConnectFrom and the layers after it to Hidden
+func NewLSTMLayer(name string, conf *Config, sess *Session, units int) (*LSTMLayer, error) {
+	sigmoid, tanh := gateConfig(conf, &Sigmoid{}), gateConfig(conf, &Tanh{})
+	product, sum := fixedConfig(&Identity{}, &ProductPreProcessor{}), fixedConfig(&Identity{}, &SumPreProcessor{})
+	l := &LSTMLayer{Name: name, Sess: sess}
+	layer := func(part string, conf *Config) *Layer {
+		created := NewLayer(name+"_"+part, conf, sess, units)
+		l.layers = append(l.layers, created)
+		return created
+	}
+	l.InputGate = layer("input_gate", sigmoid)
+	l.ForgetGate = layer("forget_gate", sigmoid)
+	l.OutputGate = layer("output_gate", sigmoid)
+	l.Candidate = layer("candidate", tanh)
+	kept := layer("kept", product)
+	added := layer("added", product)
+	l.Cell = layer("cell", sum)
+	squashed := layer("squashed", fixedConfig(&Tanh{}, &SumPreProcessor{}))
+	l.Hidden = layer("hidden", product)
+
+	steps := []func() error{
+		func() error { return connectOneToOne(l.ForgetGate.Neurons, kept.Neurons, true) },
+		func() error { return connectDelayedOneToOne(l.Cell.Neurons, kept.Neurons, 0) },
+		func() error { return connectOneToOne(l.InputGate.Neurons, added.Neurons, true) },
+		func() error { return connectOneToOne(l.Candidate.Neurons, added.Neurons, true) },
+		func() error { return connectOneToOne(kept.Neurons, l.Cell.Neurons, true) },
+		func() error { return connectOneToOne(added.Neurons, l.Cell.Neurons, true) },
+		func() error { return connectOneToOne(l.Cell.Neurons, squashed.Neurons, true) },
+		func() error { return connectOneToOne(l.OutputGate.Neurons, l.Hidden.Neurons, true) },
+		func() error { return connectOneToOne(squashed.Neurons, l.Hidden.Neurons, true) },
+	}
+	for _, gate := range l.gates() {
+		gate := gate
+		steps = append(steps, func() error { return ConnectRecurrent(l.Hidden, gate, 0) })
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("lstm layer %s: %v", name, err)
+		}
+	}
+	return l, nil
+}
+
+func (l *LSTMLayer) gates() []*Layer {
+	return []*Layer{l.InputGate, l.ForgetGate, l.OutputGate, l.Candidate}
+}
+
+// ConnectFrom connects every neuron of provider to every gate of the layer
+func (l *LSTMLayer) ConnectFrom(provider *Layer) error {
+	for _, gate := range l.gates() {
+		if err := ConnectLayers(provider, gate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Layers returns every layer of neurons the LSTM layer is made of, to turn on or save with the network
+func (l *LSTMLayer) Layers() []*Layer {
+	return l.layers
+}
+
+// State returns the hidden and cell state of the last time step
+// it waits for the time step to finish
+func (l *LSTMLayer) State() ([]float64, []float64, error) {
+	hidden, err := delayedState(l.Hidden, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	cell, err := delayedState(l.Cell, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hidden, cell, nil
+}
+
+// SetState replaces the hidden and cell state the next time step starts from
+func (l *LSTMLayer) SetState(hidden, cell []float64) error {
+	if len(hidden) != len(l.Hidden.Neurons) || len(cell) != len(l.Cell.Neurons) {
+		return fmt.Errorf("lstm layer %s has %d units", l.Name, len(l.Hidden.Neurons))
+	}
+	if _, err := delayedState(l.Hidden, hidden); err != nil {
+		return err
+	}
+	_, err := delayedState(l.Cell, cell)
+	return err
+}
+
+// GRULayer is a layer of gated recurrent units built from neurons
+// the gates and the candidate are trained, the neurons that combine them are frozen
+type GRULayer struct {
+	Name       string
+	Sess       *Session
+	UpdateGate *Layer // sigmoid of the inputs and the hidden state
+	ResetGate  *Layer // sigmoid of the inputs and the hidden state
+	Candidate  *Layer // tanh of the inputs and the reset hidden state
+	Hidden     *Layer // (1 - update) * candidate + update * previous hidden, the output of the layer
+	layers     []*Layer
+}
+
+// NewGRULayer creates a layer of units GRU cells, the gates and the candidate are trained with conf
+// connect the layers before it with ConnectFrom and the layers after it to Hidden
+func NewGRULayer(name string, conf *Config, sess *Session, units int) (*GRULayer, error) {
+	sigmoid, tanh := gateConfig(conf, &Sigmoid{}), gateConfig(conf, &Tanh{})
+	product, sum := fixedConfig(&Identity{}, &ProductPreProcessor{}), fixedConfig(&Identity{}, &SumPreProcessor{})
+	// 1 - update
+	complement := fixedConfig(&Identity{}, &SumPreProcessor{})
+	complement.BiasInitializer = &Constant{Value: 1}
+
+	l := &GRULayer{Name: name, Sess: sess}
+	layer := func(part string, conf *Config) *Layer {
+		created := NewLayer(name+"_"+part, conf, sess, units)
+		l.layers = append(l.layers, created)
+		return created
+	}
+	l.UpdateGate = layer("update_gate", sigmoid)
+	l.ResetGate = layer("reset_gate", sigmoid)
+	reset := layer("reset", product)
+	l.Candidate = layer("candidate", tanh)
+	kept := layer("kept", complement)
+	added := layer("added", product)
+	old := layer("old", product)
+	l.Hidden = layer("hidden", sum)
+
+	steps := []func() error{
+		func() error { return connectOneToOne(l.ResetGate.Neurons, reset.Neurons, true) },
+		func() error { return connectDelayedOneToOne(l.Hidden.Neurons, reset.Neurons, 0) },
+		func() error { return ConnectLayers(reset, l.Candidate) },
+		func() error { return connectOneToOne(l.UpdateGate.Neurons, kept.Neurons, true) },
+		func() error { return connectOneToOne(kept.Neurons, added.Neurons, true) },
+		func() error { return connectOneToOne(l.Candidate.Neurons, added.Neurons, true) },
+		func() error { return connectOneToOne(l.UpdateGate.Neurons, old.Neurons, true) },
+		func() error { return connectDelayedOneToOne(l.Hidden.Neurons, old.Neurons, 0) },
+		func() error { return connectOneToOne(added.Neurons, l.Hidden.Neurons, true) },
+		func() error { return connectOneToOne(old.Neurons, l.Hidden.Neurons, true) },
+		func() error { return ConnectRecurrent(l.Hidden, l.UpdateGate, 0) },
+		func() error { return ConnectRecurrent(l.Hidden, l.ResetGate, 0) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, fmt.Errorf("gru layer %s: %v", name, err)
+		}
+	}
+	for _, n := range kept.Neurons {
+		n.Inputs[0].Weight = -1
+	}
+	return l, nil
+}
+
+// ConnectFrom connects every neuron of provider to the gates and the candidate of the layer
+func (l *GRULayer) ConnectFrom(provider *Layer) error {
+	for _, gate := range []*Layer{l.UpdateGate, l.ResetGate, l.Candidate} {
+		if err := ConnectLayers(provider, gate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Layers returns every layer of neurons the GRU layer is made of, to turn on or save with the network
+func (l *GRULayer) Layers() []*Layer {
+	return l.layers
+}
+
+// State returns the hidden state of the last time step
+// it waits for the time step to finish
+func (l *GRULayer) State() ([]float64, error) {
+	return delayedState(l.Hidden, nil)
+}
+
+// SetState replaces the hidden state the next time step starts from
+func (l *GRULayer) SetState(hidden []float64) error {
+	if len(hidden) != len(l.Hidden.Neurons) {
+		return fmt.Errorf("gru layer %s has %d units", l.Name, len(l.Hidden.Neurons))
+	}
+	_, err := delayedState(l.Hidden, hidden)
+	return err
+}
+
+// delayedState returns the last value each neuron of l sent on its delayed connections,
+// and replaces it with the value at the same position of values if it is set
+func delayedState(l *Layer, values []float64) ([]float64, error) {
+	done := l.Sess.Ctx().Done()
+	state := make([]float64, len(l.Neurons))
+	for j, n := range l.Neurons {
+		for _, conn := range n.Outputs {
+			if !conn.Delayed {
+				continue
+			}
+			ok := conn.swapState(done, func(x float64) float64 {
+				state[j] = x
+				if values != nil {
+					return values[j]
+				}
+				return x
+			})
+			if !ok {
+				return nil, l.Sess.Ctx().Err()
+			}
+		}
+	}
+	return state, nil
+}
+
+// connectDelayedOneToOne connects each provider to the consumer at the same position
+// through a frozen delayed connection with a weight of 1
+func connectDelayedOneToOne(providers, consumers []*Neuron, initial float64) error {
+	for i, provider := range providers {
+		conn := NewDelayedConnection(provider.ID(), consumers[i].ID(), initial)
+		conn.Frozen = true
+		if err := consumers[i].AddInputConnections([]*Connection{conn}); err != nil {
+			return err
+		}
+		if err := provider.AddOutputConnections([]*Connection{conn}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package neuron
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// cell is an LSTM or GRU layer
+type cell interface {
+	ConnectFrom(provider *Layer) error
+	Layers() []*Layer
+}
+
+// cellNetwork builds input -> cell -> output, hidden is the output layer of the cell
+func cellNetwork(sess *Session, c cell, hidden *Layer) *Network {
+	input, err := NewInputLayer("input", &Config{Activator: &Identity{}, PreProcessor: &SumPreProcessor{}, Initializer: &Constant{Value: 1}}, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := c.ConnectFrom(input.Layer); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", &Config{Activator: &Identity{}, PreProcessor: &SumPreProcessor{}, Initializer: &Xavier{}}, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	return &Network{Sess: sess, Input: input, Layers: c.Layers(), Output: output}
+}
+
+// delaySequences are sequences where the target is the input of the previous time step
+func delaySequences() [][]Sample {
+	var sequences [][]Sample
+	for s := 0; s < 4; s++ {
+		seq := make([]Sample, 8)
+		prev := 0.0
+		for i := range seq {
+			x := math.Sin(float64(s*8+i) * 1.7)
+			seq[i] = Sample{X: []float64{x}, Y: []float64{prev}}
+			prev = x
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences
+}
+
+func TestCellsLearnSequences(t *testing.T) {
+	conf := &Config{Initializer: &Xavier{}}
+	for _, name := range []string{"lstm", "gru"} {
+		sess := NewSession(0.1).SetSeed(4)
+		var net *Network
+		switch name {
+		case "lstm":
+			l, err := NewLSTMLayer(name, conf, sess, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			net = cellNetwork(sess, l, l.Hidden)
+		case "gru":
+			l, err := NewGRULayer(name, conf, sess, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			net = cellNetwork(sess, l, l.Hidden)
+		}
+		net.On()
+		trainer := NewTrainer(sess, net.Input, net.Output)
+		sequences := delaySequences()
+		first, err := trainer.FitSequences(sequences, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last, err := trainer.FitSequences(sequences, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Printf("%s loss: first %.05f last %.05f\n", name, first, last)
+		if last >= first {
+			t.Errorf("%s: expected the loss to improve, got %.05f after %.05f", name, last, first)
+		}
+		shutdown(t, sess)
+	}
+}
+
+func TestLSTMState(t *testing.T) {
+	sess := NewSession(0.1).SetSeed(8)
+	l, err := NewLSTMLayer("lstm", &Config{Initializer: &Xavier{}}, sess, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := cellNetwork(sess, l, l.Hidden)
+	net.On()
+	defer shutdown(t, sess)
+	trainer := NewTrainer(sess, net.Input, net.Output)
+
+	hidden, cell, err := l.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hidden, []float64{0, 0}) || !reflect.DeepEqual(cell, []float64{0, 0}) {
+		t.Errorf("got initial state %v %v, want zeros", hidden, cell)
+	}
+	xs := [][]float64{{1}, {-0.5}, {0.25}}
+	if _, err := trainer.RunSequence(xs); err != nil {
+		t.Fatal(err)
+	}
+	hidden, cell, err = l.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range hidden {
+		// the hidden state is the output gate times tanh of the cell state
+		if math.Abs(hidden[i]) > math.Abs(math.Tanh(cell[i]))+1e-12 {
+			t.Errorf("unit %d: hidden state %v is larger than tanh of the cell state %v", i, hidden[i], cell[i])
+		}
+	}
+	want, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// starting again from the saved state gives the same outputs
+	if err := l.SetState(hidden, cell); err != nil {
+		t.Fatal(err)
+	}
+	got, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after restoring the state, want %v", got, want)
+	}
+	if err := l.SetState([]float64{0}, cell); err == nil {
+		t.Errorf("expected a state of the wrong size to be refused")
+	}
+}
+
+func TestGRUSaveLoad(t *testing.T) {
+	sess := NewSession(0.1).SetSeed(6)
+	l, err := NewGRULayer("gru", &Config{Initializer: &Xavier{}}, sess, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := cellNetwork(sess, l, l.Hidden)
+	net.On()
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	if _, err := trainer.FitSequences(delaySequences(), 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetState([]float64{0.5, -0.5}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := l.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state, []float64{0.5, -0.5}) {
+		t.Errorf("got state %v want [0.5 -0.5]", state)
+	}
+	if err := sess.ResetState(); err != nil {
+		t.Fatal(err)
+	}
+	xs := [][]float64{{1}, {0}, {-1}}
+	want, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Save(&buf, FORMAT_GOB, net); err != nil {
+		t.Fatal(err)
+	}
+	shutdown(t, sess)
+	loaded, err := Load(&buf, FORMAT_GOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.On()
+	defer shutdown(t, loaded.Sess)
+	got, err := NewTrainer(loaded.Sess, loaded.Input, loaded.Output).RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded network got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package neuron
+
+import (
+	"math"
+	"sort"
+)
+
+// Softmax turns xs into a probability distribution
+func Softmax(xs []float64) []float64 {
+	probs := make([]float64, len(xs))
+	if len(xs) == 0 {
+		return probs
+	}
+	// subtract the largest value so the exponentials can not overflow
+	max := xs[0]
+	for _, x := range xs[1:] {
+		max = math.Max(max, x)
+	}
+	var sum float64
+	for i, x := range xs {
+		probs[i] = math.Exp(x - max)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
+
+// ArgMax returns the index of the largest value, -1 if xs is empty
+func ArgMax(xs []float64) int {
+	best := -1
+	for i, x := range xs {
+		if best < 0 || x > xs[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// TopK returns the indices of the k largest values, largest first
+func TopK(xs []float64, k int) []int {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return xs[idx[i]] > xs[idx[j]] })
+	if k < 0 {
+		k = 0
+	}
+	if k < len(idx) {
+		idx = idx[:k]
+	}
+	return idx
+}
@@ -0,0 +1,107 @@
+package neuron
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSoftmaxHelpers(t *testing.T) {
+	probs := Softmax([]float64{1000, 1001, 999})
+	var sum float64
+	for _, p := range probs {
+		sum += p
+	}
+	if math.Abs(sum-1) > 1e-12 || ArgMax(probs) != 1 {
+		t.Errorf("unexpected softmax %v", probs)
+	}
+	if got := TopK([]float64{0.1, 0.5, 0.2, 0.9}, 2); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("got top 2 %v want [3 1]", got)
+	}
+	if got := TopK([]float64{0.1, 0.5}, 5); !reflect.DeepEqual(got, []int{1, 0}) {
+		t.Errorf("got top 5 %v want [1 0]", got)
+	}
+	if ArgMax(nil) != -1 {
+		t.Errorf("expected -1 for no values")
+	}
+}
+
+func TestSoftmaxClassifier(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Identity{},
+		PreProcessor: &SumPreProcessor{},
+		Optimizer:    &Adam{},
+	}
+	sess := NewSession(0.05)
+	input, err := NewInputLayer("input", conf, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(input.Layer, output.Layer); err != nil {
+		panic(err)
+	}
+	output.Softmax = true
+	output.Cost = &CategoricalCrossEntropy{}
+	input.Layer.On()
+	output.Layer.On()
+	defer shutdown(t, sess)
+
+	// the class is the input that is switched on
+	dataset := make([]Sample, 60)
+	for i := range dataset {
+		class := i % 3
+		x := make([]float64, 3)
+		y := make([]float64, 3)
+		for j := range x {
+			x[j] = rand.Float64() * 0.2
+		}
+		x[class] = 1
+		y[class] = 1
+		dataset[i] = Sample{X: x, Y: y}
+	}
+
+	trainer := NewTrainer(sess, input, output)
+	if _, err := trainer.Fit(dataset, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	probs, err := trainer.Predict(dataset[0].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, p := range probs {
+		sum += p
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("outputs do not sum to 1: %v", probs)
+	}
+
+	correct := 0
+	for _, s := range dataset {
+		class, err := trainer.PredictClass(s.X)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if class == ArgMax(s.Y) {
+			correct++
+		}
+	}
+	t.Logf("accuracy %d/%d", correct, len(dataset))
+	if correct < len(dataset)*9/10 {
+		t.Errorf("accuracy %d/%d is too low", correct, len(dataset))
+	}
+	top, err := trainer.PredictTopK(dataset[1].X, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0] != 1 {
+		t.Errorf("unexpected top 2 %v", top)
+	}
+}
@@ -8,4 +8,10 @@ type Config struct {
 	NumSignals    int
 	MaxWeight     float64
 	MaxBias       float64
+	Optimizer     Optimizer // SGD if nil
+	Initializer   Initializer // weights of the connections into a neuron, unit normal if nil
+	// BiasInitializer draws the bias of a neuron, unit normal if nil
+	// biases are drawn before the neuron is connected so it sees a fan-in and fan-out of zero
+	BiasInitializer Initializer
+	Frozen          bool // the neurons never train their weights, bias or activator
 }
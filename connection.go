@@ -2,6 +2,10 @@ package neuron
 
 type Packet struct {
 	NeuronID *NeuronID
+	SampleID uint64 // the sample the packet belongs to, passed on by every neuron
+	// Mode is the mode of the session when the sample entered the network, set by the input layer
+	// it is empty for packets that did not come through an input layer, their neuron uses the mode of the session
+	Mode Mode
 	X        float64
 }
 
@@ -9,6 +13,12 @@ type Connection struct {
 	ProvidingNeuron *NeuronID
 	ConsumingNeuron *NeuronID
 	Forward         chan *Packet
+	Backward        chan *Packet // carries the gradient of the loss from the consumer back to the provider
+	Weight          float64      // scales every X sent forward, trained by the consuming neuron
+	Frozen          bool         // the weight is never trained
+	Delayed         bool         // the consumer receives the value the provider sent in the previous time step
+	Initial         float64      // value a delayed connection delivers in the first time step
+	weightState     OptimizerState
 }
 
 func NewConnection(provider, consumer *NeuronID) *Connection {
@@ -16,5 +26,30 @@ func NewConnection(provider, consumer *NeuronID) *Connection {
 		ProvidingNeuron: provider,
 		ConsumingNeuron: consumer,
 		Forward:         make(chan *Packet, 1),
+		Backward:        make(chan *Packet, 1),
+		Weight:          1,
 	}
 }
+
+// NewDelayedConnection creates a recurrent connection, it delivers the provider's value of the previous time step
+// so it can close a cycle of neurons without a deadlock, the first time step gets initial
+func NewDelayedConnection(provider, consumer *NeuronID, initial float64) *Connection {
+	conn := NewConnection(provider, consumer)
+	conn.Delayed = true
+	conn.Initial = initial
+	conn.Forward <- &Packet{NeuronID: provider, X: initial}
+	return conn
+}
+
+// swapState waits for the value of the last time step on a delayed connection and replaces it with next(value)
+// every delayed connection holds exactly one value between time steps
+func (c *Connection) swapState(done <-chan struct{}, next func(x float64) float64) bool {
+	var p *Packet
+	select {
+	case p = <-c.Forward:
+	case <-done:
+		return false
+	}
+	c.Forward <- &Packet{NeuronID: c.ProvidingNeuron, SampleID: p.SampleID, Mode: p.Mode, X: next(p.X)}
+	return true
+}
@@ -0,0 +1,131 @@
+package neuron
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Connectivity decides which providers are connected to which consumers
+// every provider needs at least one consumer and every consumer at least one provider,
+// otherwise a neuron would wait forever for a gradient or send without waiting for inputs
+type Connectivity interface {
+	// Mask returns mask[i][j], true if provider i is connected to consumer j
+	// random choices are drawn from rng so a seeded session builds the same network every time
+	Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error)
+}
+
+// Dense connects every provider to every consumer, it is the default
+type Dense struct{}
+
+func (c *Dense) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	return mask(providers, consumers, func(i, j int) bool { return true }), nil
+}
+
+// Sparse keeps each connection with probability Density
+// every provider keeps at least one output and every consumer at least one input, so no neuron is left waiting
+type Sparse struct {
+	Density float64
+	Seed    int64 // if set the connections only depend on it, otherwise they are drawn from the session
+}
+
+func (c *Sparse) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	if c.Density <= 0 || c.Density > 1 {
+		return nil, fmt.Errorf("sparse density must be in (0, 1], got %v", c.Density)
+	}
+	if c.Seed != 0 {
+		rng = rand.New(rand.NewSource(c.Seed))
+	}
+	m := mask(providers, consumers, func(i, j int) bool { return rng.Float64() < c.Density })
+	outputs, inputs := make([]int, providers), make([]int, consumers)
+	for i := range m {
+		for j, connected := range m[i] {
+			if connected {
+				outputs[i]++
+				inputs[j]++
+			}
+		}
+	}
+	for i, n := range outputs {
+		if n == 0 {
+			j := rng.Intn(consumers)
+			m[i][j] = true
+			inputs[j]++
+		}
+	}
+	for j, n := range inputs {
+		if n == 0 {
+			m[rng.Intn(providers)][j] = true
+		}
+	}
+	return m, nil
+}
+
+// OneToOne connects each provider to the consumer at the same position, the layers must be the same size
+type OneToOne struct{}
+
+func (c *OneToOne) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	if providers != consumers {
+		return nil, fmt.Errorf("one to one needs the same number of providers and consumers, got %d and %d", providers, consumers)
+	}
+	return mask(providers, consumers, func(i, j int) bool { return i == j }), nil
+}
+
+// Banded connects each consumer to the providers within Radius of its position, a local receptive field
+// if the layers are not the same size each neuron is centred on the neuron at the same relative position of the other layer,
+// and connected to the neurons within Radius of its centre, so every neuron has at least one connection
+type Banded struct {
+	Radius int
+}
+
+func (c *Banded) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	if c.Radius < 0 {
+		return nil, fmt.Errorf("banded radius must not be negative, got %d", c.Radius)
+	}
+	near := func(k, centre int) bool {
+		return k >= centre-c.Radius && k <= centre+c.Radius
+	}
+	return mask(providers, consumers, func(i, j int) bool {
+		return near(i, (2*j+1)*providers/(2*consumers)) || near(j, (2*i+1)*consumers/(2*providers))
+	}), nil
+}
+
+// Grouped splits the providers and the consumers into Groups groups in order,
+// each group of providers is connected only to the group of consumers at the same position
+type Grouped struct {
+	Groups int
+}
+
+func (c *Grouped) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	if c.Groups < 1 || c.Groups > providers || c.Groups > consumers {
+		return nil, fmt.Errorf("can not split %d providers and %d consumers into %d groups", providers, consumers, c.Groups)
+	}
+	return mask(providers, consumers, func(i, j int) bool {
+		return i*c.Groups/providers == j*c.Groups/consumers
+	}), nil
+}
+
+// Predicate connects provider i to consumer j if it returns true
+type Predicate func(i, j int) bool
+
+func (c Predicate) Mask(providers, consumers int, rng *rand.Rand) ([][]bool, error) {
+	return mask(providers, consumers, c), nil
+}
+
+func mask(providers, consumers int, connected func(i, j int) bool) [][]bool {
+	m := make([][]bool, providers)
+	for i := range m {
+		m[i] = make([]bool, consumers)
+		for j := range m[i] {
+			m[i][j] = connected(i, j)
+		}
+	}
+	return m
+}
+
+// connectivity returns c, or Dense if it is nil
+func connectivity(c Connectivity) Connectivity {
+	if c == nil {
+		return &Dense{}
+	}
+	return c
+}
@@ -0,0 +1,284 @@
+package neuron
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestConnectivityMasks(t *testing.T) {
+	count := func(m [][]bool) (int, []int, []int) {
+		var total int
+		outputs, inputs := make([]int, len(m)), make([]int, len(m[0]))
+		for i := range m {
+			for j, connected := range m[i] {
+				if connected {
+					total++
+					outputs[i]++
+					inputs[j]++
+				}
+			}
+		}
+		return total, outputs, inputs
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	m, err := (&Dense{}).Mask(3, 4, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, _, _ := count(m); total != 12 {
+		t.Errorf("dense: got %d connections want 12", total)
+	}
+
+	m, err = (&Sparse{Density: 0.1}).Mask(100, 500, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, outputs, inputs := count(m)
+	fmt.Printf("sparse: %d of 50000 connections\n", total)
+	if total < 4500 || total > 5500 {
+		t.Errorf("sparse: got %d connections, want about 5000", total)
+	}
+	sparse, err := (&Sparse{Density: 0.001}).Mask(100, 500, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, outputs, inputs = count(sparse)
+	for i, n := range outputs {
+		if n == 0 {
+			t.Errorf("sparse: provider %d has no outputs", i)
+		}
+	}
+	for j, n := range inputs {
+		if n == 0 {
+			t.Errorf("sparse: consumer %d has no inputs", j)
+		}
+	}
+	a, _ := (&Sparse{Density: 0.3, Seed: 7}).Mask(10, 10, rand.New(rand.NewSource(1)))
+	b, _ := (&Sparse{Density: 0.3, Seed: 7}).Mask(10, 10, rand.New(rand.NewSource(2)))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("sparse: expected the same seed to give the same connections")
+	}
+	if _, err := (&Sparse{Density: 0}).Mask(2, 2, rng); err == nil {
+		t.Errorf("sparse: expected a density of 0 to be refused")
+	}
+
+	m, err = (&OneToOne{}).Mask(3, 3, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, [][]bool{{true, false, false}, {false, true, false}, {false, false, true}}) {
+		t.Errorf("one to one: got %v", m)
+	}
+	if _, err := (&OneToOne{}).Mask(3, 4, rng); err == nil {
+		t.Errorf("one to one: expected layers of different sizes to be refused")
+	}
+
+	m, err = (&Banded{Radius: 1}).Mask(5, 5, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, inputs = count(m)
+	if !reflect.DeepEqual(inputs, []int{2, 3, 3, 3, 2}) {
+		t.Errorf("banded: got %v inputs per consumer", inputs)
+	}
+	// a smaller consumer layer looks at the providers around its scaled position, and every provider has a consumer
+	m, err = (&Banded{Radius: 0}).Mask(6, 3, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, outputs, inputs := count(m); total != 6 || !reflect.DeepEqual(outputs, []int{1, 1, 1, 1, 1, 1}) || !reflect.DeepEqual(inputs, []int{2, 2, 2}) {
+		t.Errorf("banded: got %v", m)
+	}
+	m, err = (&Banded{Radius: 0}).Mask(2, 5, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, outputs, inputs := count(m); total != 5 || !reflect.DeepEqual(outputs, []int{2, 3}) || !reflect.DeepEqual(inputs, []int{1, 1, 1, 1, 1}) {
+		t.Errorf("banded: got %v", m)
+	}
+
+	m, err = (&Grouped{Groups: 2}).Mask(4, 6, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bool{
+		{true, true, true, false, false, false},
+		{true, true, true, false, false, false},
+		{false, false, false, true, true, true},
+		{false, false, false, true, true, true},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("grouped: got %v", m)
+	}
+	if _, err := (&Grouped{Groups: 5}).Mask(4, 6, rng); err == nil {
+		t.Errorf("grouped: expected more groups than providers to be refused")
+	}
+
+	m, err = Predicate(func(i, j int) bool { return j >= i }).Mask(3, 3, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, _, _ := count(m); total != 6 || m[1][0] {
+		t.Errorf("predicate: got %v", m)
+	}
+}
+
+func TestSparseNetwork(t *testing.T) {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+		Initializer:  &Xavier{},
+	}
+	build := func(seed int64) *Network {
+		sess := NewSession(0.05).SetSeed(seed)
+		input, err := NewInputLayer("input", conf, sess, 4)
+		if err != nil {
+			panic(err)
+		}
+		hidden := NewLayer("hidden", conf, sess, 16)
+		if err := ConnectLayersWith(input.Layer, hidden, &Sparse{Density: 0.3}); err != nil {
+			panic(err)
+		}
+		grouped := NewLayer("grouped", conf, sess, 8)
+		if err := ConnectLayersWith(hidden, grouped, &Grouped{Groups: 4}); err != nil {
+			panic(err)
+		}
+		output, err := NewOutputLayer("output", &Config{Activator: &Identity{}, PreProcessor: &SumPreProcessor{}}, sess, 2)
+		if err != nil {
+			panic(err)
+		}
+		if err := ConnectLayers(grouped, output.Layer); err != nil {
+			panic(err)
+		}
+		return &Network{Sess: sess, Input: input, Layers: []*Layer{hidden, grouped}, Output: output}
+	}
+	weights := func(net *Network) []float64 {
+		var ws []float64
+		for _, l := range net.Layers {
+			for _, n := range l.Neurons {
+				for _, conn := range n.Inputs {
+					ws = append(ws, conn.Weight)
+				}
+			}
+		}
+		return ws
+	}
+
+	net := build(3)
+	if ws := weights(net); len(ws) >= 4*16+16*8 {
+		t.Errorf("expected fewer connections than a dense network, got %d", len(ws))
+	}
+	if !reflect.DeepEqual(weights(net), weights(build(3))) {
+		t.Errorf("expected the same seed to build the same network")
+	}
+
+	net.On()
+	dataset := []Sample{
+		{X: []float64{1, 0, 0, 0}, Y: []float64{1, 0}},
+		{X: []float64{0, 1, 0, 0}, Y: []float64{0, 1}},
+		{X: []float64{0, 0, 1, 0}, Y: []float64{1, 1}},
+		{X: []float64{0, 0, 0, 1}, Y: []float64{0, 0}},
+	}
+	trainer := NewTrainer(net.Sess, net.Input, net.Output)
+	first, err := trainer.Fit(dataset, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := trainer.Fit(dataset, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("sparse network loss: first %.05f last %.05f\n", first, last)
+	if last >= first {
+		t.Errorf("expected the loss to improve, got %.05f after %.05f", last, first)
+	}
+	want, err := trainer.Predict(dataset[2].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the connections that were made are saved
+	var buf bytes.Buffer
+	if err := Save(&buf, FORMAT_GOB, net); err != nil {
+		t.Fatal(err)
+	}
+	shutdown(t, net.Sess)
+	loaded, err := Load(&buf, FORMAT_GOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.On()
+	defer shutdown(t, loaded.Sess)
+	got, err := NewTrainer(loaded.Sess, loaded.Input, loaded.Output).Predict(dataset[2].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded network got %v want %v", got, want)
+	}
+}
+
+func TestBandedNetwork(t *testing.T) {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+		Initializer:  &Xavier{},
+	}
+	sess := NewSession(0.05).SetSeed(5)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 6)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", &Config{Activator: &Identity{}, PreProcessor: &SumPreProcessor{}}, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	// every hidden neuron still gets a gradient back from an output
+	if err := ConnectLayersWith(hidden, output.Layer, &Banded{Radius: 0}); err != nil {
+		panic(err)
+	}
+	net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+
+	dataset := []Sample{
+		{X: []float64{1, 0}, Y: []float64{1, 0, -1}},
+		{X: []float64{0, 1}, Y: []float64{0, 1, 0}},
+		{X: []float64{1, 1}, Y: []float64{1, 1, -1}},
+	}
+	trainer := NewTrainer(sess, input, output)
+	first, err := trainer.Fit(dataset, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := trainer.Fit(dataset, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("banded network loss: first %.05f last %.05f\n", first, last)
+	if last >= first {
+		t.Errorf("expected the loss to improve, got %.05f after %.05f", last, first)
+	}
+
+	// a pattern that leaves a neuron unconnected is refused before anything is connected
+	a, b := NewLayer("a", conf, sess, 3), NewLayer("b", conf, sess, 3)
+	if err := ConnectLayersWith(a, b, Predicate(func(i, j int) bool { return j > 0 })); err == nil {
+		t.Errorf("expected a consumer without providers to be refused")
+	}
+	if err := ConnectLayersWith(a, b, Predicate(func(i, j int) bool { return i > 0 })); err == nil {
+		t.Errorf("expected a provider without consumers to be refused")
+	}
+	for _, n := range append(a.Neurons, b.Neurons...) {
+		if len(n.Inputs) != 0 || len(n.Outputs) != 0 {
+			t.Errorf("expected no connections to be made, neuron %d has %d inputs and %d outputs", n.id, len(n.Inputs), len(n.Outputs))
+		}
+	}
+}
@@ -5,32 +5,179 @@ import (
 	"math"
 )
 
+// Cost measures how far predictions are from their targets
+type Cost interface {
+	// Cost returns the loss and the gradient of the loss with respect to each prediction
+	Cost(predictions, targets []float64) (float64, []float64, error)
+}
+
+// epsilon keeps logarithms and divisions away from zero
+const epsilon = 1e-12
 
-func meanAbsoluteError(predictions, targets []float64) (float64, error) {
+func checkLengths(predictions, targets []float64) error {
 	if len(predictions) != len(targets) {
-		return 0, fmt.Errorf("length of predictions does not match length of targets")
+		return fmt.Errorf("length of predictions does not match length of targets")
+	}
+	if len(predictions) == 0 {
+		return fmt.Errorf("no predictions")
+	}
+	return nil
+}
+
+type MeanAbsoluteError struct{}
+
+func (c *MeanAbsoluteError) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
 	}
 
+	n := float64(len(predictions))
 	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
 
 	for i, p := range predictions {
-		errAcc += math.Abs(p - targets[i])
+		d := p - targets[i]
+		errAcc += math.Abs(d)
+		if d > 0 {
+			grads[i] = 1 / n
+		} else if d < 0 {
+			grads[i] = -1 / n
+		}
 	}
 
-	return (1.0 / float64(len(predictions))) * errAcc, nil
+	return (1.0 / n) * errAcc, grads, nil
 }
 
+// MeanSquaredError is halved so its gradient is simply the mean error
+type MeanSquaredError struct{}
 
-func meanSquaredError(predictions, targets []float64) (float64, error) {
-	if len(predictions) != len(targets) {
-		return 0, fmt.Errorf("length of predictions does not match length of targets")
+func (c *MeanSquaredError) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
+	}
+
+	n := float64(len(predictions))
+	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
+
+	for i, p := range predictions {
+		errAcc += math.Pow(p-targets[i], 2)
+		grads[i] = (p - targets[i]) / n
+	}
+
+	return (1.0 / (2 * n)) * errAcc, grads, nil
+}
+
+// Huber is quadratic for errors smaller than Delta and linear beyond, Delta is 1 if zero
+type Huber struct {
+	Delta float64
+}
+
+func (c *Huber) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
+	}
+
+	delta := orDefault(c.Delta, 1)
+	n := float64(len(predictions))
+	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
+
+	for i, p := range predictions {
+		d := p - targets[i]
+		if math.Abs(d) <= delta {
+			errAcc += 0.5 * d * d
+			grads[i] = d / n
+		} else {
+			errAcc += delta * (math.Abs(d) - 0.5*delta)
+			grads[i] = delta * math.Copysign(1, d) / n
+		}
+	}
+
+	return errAcc / n, grads, nil
+}
+
+type LogCosh struct{}
+
+func (c *LogCosh) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
+	}
+
+	n := float64(len(predictions))
+	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
+
+	for i, p := range predictions {
+		d := p - targets[i]
+		// log(cosh(d)) written so it does not overflow for large d
+		errAcc += math.Abs(d) + math.Log1p(math.Exp(-2*math.Abs(d))) - math.Ln2
+		grads[i] = math.Tanh(d) / n
+	}
+
+	return errAcc / n, grads, nil
+}
+
+// BinaryCrossEntropy expects predictions and targets between 0 and 1
+type BinaryCrossEntropy struct{}
+
+func (c *BinaryCrossEntropy) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
+	}
+
+	n := float64(len(predictions))
+	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
+
+	for i, p := range predictions {
+		p = math.Min(math.Max(p, epsilon), 1-epsilon)
+		t := targets[i]
+		errAcc -= t*math.Log(p) + (1-t)*math.Log(1-p)
+		grads[i] = (p - t) / (p * (1 - p)) / n
+	}
+
+	return errAcc / n, grads, nil
+}
+
+// CategoricalCrossEntropy expects predictions that form a probability distribution and one-hot or soft targets
+type CategoricalCrossEntropy struct{}
+
+func (c *CategoricalCrossEntropy) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
 	}
 
 	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
 
 	for i, p := range predictions {
-		errAcc += math.Pow(p - targets[i], 2)
+		p = math.Max(p, epsilon)
+		errAcc -= targets[i] * math.Log(p)
+		grads[i] = -targets[i] / p
 	}
 
-	return (1.0 / float64(2 * len(predictions))) * errAcc, nil
-}
\ No newline at end of file
+	return errAcc, grads, nil
+}
+
+// Hinge expects targets of -1 or 1
+type Hinge struct{}
+
+func (c *Hinge) Cost(predictions, targets []float64) (float64, []float64, error) {
+	if err := checkLengths(predictions, targets); err != nil {
+		return 0, nil, err
+	}
+
+	n := float64(len(predictions))
+	errAcc := float64(0)
+	grads := make([]float64, len(predictions))
+
+	for i, p := range predictions {
+		if margin := 1 - targets[i]*p; margin > 0 {
+			errAcc += margin
+			grads[i] = -targets[i] / n
+		}
+	}
+
+	return errAcc / n, grads, nil
+}
@@ -0,0 +1,47 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostGradients(t *testing.T) {
+	tests := []struct {
+		name        string
+		cost        Cost
+		predictions []float64
+		targets     []float64
+	}{
+		{"mae", &MeanAbsoluteError{}, []float64{1.5, -2, 0.3}, []float64{1, 0, 0.1}},
+		{"mse", &MeanSquaredError{}, []float64{1.5, -2, 0.3}, []float64{1, 0, 0.1}},
+		{"huber", &Huber{Delta: 1}, []float64{1.5, -2, 0.3}, []float64{1, 0, 0.1}},
+		{"log cosh", &LogCosh{}, []float64{1.5, -2, 0.3}, []float64{1, 0, 0.1}},
+		{"binary cross entropy", &BinaryCrossEntropy{}, []float64{0.9, 0.2, 0.6}, []float64{1, 0, 1}},
+		{"categorical cross entropy", &CategoricalCrossEntropy{}, []float64{0.7, 0.2, 0.1}, []float64{1, 0, 0}},
+		{"hinge", &Hinge{}, []float64{0.5, -2, 0.3}, []float64{1, -1, -1}},
+	}
+
+	const h = 1e-6
+	for _, tt := range tests {
+		_, grads, err := tt.cost.Cost(tt.predictions, tt.targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tt.predictions {
+			up := append([]float64{}, tt.predictions...)
+			down := append([]float64{}, tt.predictions...)
+			up[i] += h
+			down[i] -= h
+			lossUp, _, _ := tt.cost.Cost(up, tt.targets)
+			lossDown, _, _ := tt.cost.Cost(down, tt.targets)
+			numerical := (lossUp - lossDown) / (2 * h)
+			if math.Abs(numerical-grads[i]) > 1e-5 {
+				t.Errorf("%s: gradient %d is %.06f, numerically %.06f", tt.name, i, grads[i], numerical)
+			}
+		}
+	}
+
+	if _, _, err := (&MeanSquaredError{}).Cost([]float64{1}, []float64{1, 2}); err == nil {
+		t.Errorf("expected an error for mismatched lengths")
+	}
+}
@@ -0,0 +1,367 @@
+package neuron
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NodeKind string
+
+const (
+	NODE_INPUT  = NodeKind("input")
+	NODE_HIDDEN = NodeKind("hidden")
+	NODE_OUTPUT = NodeKind("output")
+)
+
+// Graph describes a network as named layers and the connections between them
+// nothing is built until Build, which checks the whole graph first
+type Graph struct {
+	Sess  *Session
+	nodes []*graphNode
+	edges []graphEdge
+}
+
+type graphNode struct {
+	name    string
+	kind    NodeKind
+	conf    *Config
+	neurons int
+}
+
+type graphEdge struct {
+	provider string
+	consumer string
+	residual bool
+	delayed  bool
+	initial  float64
+}
+
+func NewGraph(sess *Session) *Graph {
+	return &Graph{Sess: sess}
+}
+
+// Input adds the input layer of the network
+func (g *Graph) Input(name string, conf *Config, neurons int) *Graph {
+	return g.add(name, NODE_INPUT, conf, neurons)
+}
+
+// Layer adds a hidden layer
+func (g *Graph) Layer(name string, conf *Config, neurons int) *Graph {
+	return g.add(name, NODE_HIDDEN, conf, neurons)
+}
+
+// Output adds the output layer of the network
+func (g *Graph) Output(name string, conf *Config, neurons int) *Graph {
+	return g.add(name, NODE_OUTPUT, conf, neurons)
+}
+
+// Connect connects every neuron of the provider layer to every neuron of the consumer layer
+// the provider does not have to be the layer right before the consumer, see Layer.Concat
+func (g *Graph) Connect(provider, consumer string) *Graph {
+	g.edges = append(g.edges, graphEdge{provider: provider, consumer: consumer})
+	return g
+}
+
+// Residual adds the outputs of layer x to the inputs of the consumer one to one, see Layer.Residual
+func (g *Graph) Residual(x, consumer string) *Graph {
+	g.edges = append(g.edges, graphEdge{provider: x, consumer: consumer, residual: true})
+	return g
+}
+
+// Recurrent connects the provider to the consumer through delayed connections, see ConnectRecurrent
+// the cycles they close are intended, so they are not reported by Validate
+func (g *Graph) Recurrent(provider, consumer string, initial float64) *Graph {
+	g.edges = append(g.edges, graphEdge{provider: provider, consumer: consumer, delayed: true, initial: initial})
+	return g
+}
+
+func (g *Graph) add(name string, kind NodeKind, conf *Config, neurons int) *Graph {
+	g.nodes = append(g.nodes, &graphNode{name: name, kind: kind, conf: conf, neurons: neurons})
+	return g
+}
+
+// GraphError is a problem with a layer, a connection or a cycle of a Graph
+type GraphError struct {
+	Nodes   []string // the layer, the provider and consumer of a connection, or the layers of a cycle
+	Problem string
+}
+
+func (e *GraphError) Error() string {
+	if len(e.Nodes) == 0 {
+		return e.Problem
+	}
+	return strings.Join(e.Nodes, " -> ") + ": " + e.Problem
+}
+
+// GraphErrors is every problem found in a Graph
+type GraphErrors []*GraphError
+
+func (e GraphErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems in graph: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Validate returns GraphErrors listing every problem with the graph, or nil if it can be built
+func (g *Graph) Validate() error {
+	var errs GraphErrors
+	problem := func(nodes []string, format string, args ...interface{}) {
+		errs = append(errs, &GraphError{Nodes: nodes, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	byName := map[string]*graphNode{}
+	var inputs, outputs []*graphNode
+	for _, n := range g.nodes {
+		if _, ok := byName[n.name]; ok {
+			problem([]string{n.name}, "duplicate layer name")
+			continue
+		}
+		byName[n.name] = n
+		if n.conf == nil {
+			problem([]string{n.name}, "layer has no config")
+		}
+		if n.neurons < 1 {
+			problem([]string{n.name}, "layer has %d neurons", n.neurons)
+		}
+		switch n.kind {
+		case NODE_INPUT:
+			inputs = append(inputs, n)
+		case NODE_OUTPUT:
+			outputs = append(outputs, n)
+		}
+	}
+	if len(inputs) != 1 {
+		problem(nil, "graph has %d input layers, it needs one", len(inputs))
+	}
+	if len(outputs) != 1 {
+		problem(nil, "graph has %d output layers, it needs one", len(outputs))
+	}
+
+	consumers := map[string][]string{}
+	in, out := map[string]int{}, map[string]int{}
+	seen := map[graphEdge]bool{}
+	for _, e := range g.edges {
+		p, pok := byName[e.provider]
+		c, cok := byName[e.consumer]
+		pair := graphEdge{provider: e.provider, consumer: e.consumer, delayed: e.delayed}
+		switch {
+		case !pok:
+			problem([]string{e.provider, e.consumer}, "unknown provider %q", e.provider)
+		case !cok:
+			problem([]string{e.provider, e.consumer}, "unknown consumer %q", e.consumer)
+		case seen[pair]:
+			problem([]string{e.provider, e.consumer}, "duplicate connection")
+		case c.kind == NODE_INPUT:
+			problem([]string{e.provider, e.consumer}, "an input layer can not consume")
+		case p.kind == NODE_OUTPUT && !e.delayed:
+			problem([]string{e.provider, e.consumer}, "an output layer can not provide")
+		case e.residual && p.neurons != c.neurons:
+			problem([]string{e.provider, e.consumer}, "residual has %d neurons, the layer has %d", p.neurons, c.neurons)
+		case e.delayed:
+			// a delayed connection does not carry the current time step, so it does not count as an input
+			seen[pair] = true
+		default:
+			seen[pair] = true
+			consumers[e.provider] = append(consumers[e.provider], e.consumer)
+			if !e.residual {
+				in[e.consumer]++
+			}
+			out[e.provider]++
+		}
+	}
+
+	for _, cycle := range g.cycles(consumers) {
+		problem(cycle, "cycle")
+	}
+
+	reachable := map[string]bool{}
+	var reach func(name string)
+	reach = func(name string) {
+		if reachable[name] {
+			return
+		}
+		reachable[name] = true
+		for _, c := range consumers[name] {
+			reach(c)
+		}
+	}
+	for _, n := range inputs {
+		reach(n.name)
+	}
+	for _, n := range g.nodes {
+		if byName[n.name] != n {
+			continue
+		}
+		if n.kind != NODE_INPUT && in[n.name] == 0 {
+			// a residual is added to the inputs, it is not an input by itself
+			problem([]string{n.name}, "layer has no inputs")
+		} else if len(inputs) > 0 && !reachable[n.name] {
+			problem([]string{n.name}, "layer can not be reached from the input layer")
+		}
+		if n.kind != NODE_OUTPUT && out[n.name] == 0 {
+			problem([]string{n.name}, "layer has no outputs")
+		}
+	}
+
+	if len(errs) == 0 {
+		// relays can delay a residual, but not make the other inputs of its layer arrive later
+		depths := map[string]int{}
+		for _, name := range g.order() {
+			inputs := -1
+			for _, e := range g.edges {
+				if e.consumer == name && !e.residual && !e.delayed && depths[e.provider] > inputs {
+					inputs = depths[e.provider]
+				}
+			}
+			for _, e := range g.edges {
+				if e.consumer == name && e.residual && depths[e.provider] > inputs {
+					problem([]string{e.provider, e.consumer}, "residual is further from the input layer than the inputs of the layer")
+				}
+			}
+			depths[name] = inputs + 1
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// cycles returns every cycle found by a depth first search, each starting and ending at the same layer
+func (g *Graph) cycles(consumers map[string][]string) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var (
+		path   []string
+		cycles [][]string
+		visit  func(name string)
+	)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, c := range consumers[name] {
+			switch state[c] {
+			case unvisited:
+				visit(c)
+			case visiting:
+				for i := range path {
+					if path[i] == c {
+						cycles = append(cycles, append(append([]string{}, path[i:]...), c))
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, n := range g.nodes {
+		if state[n.name] == unvisited {
+			visit(n.name)
+		}
+	}
+	return cycles
+}
+
+// Build validates the graph, then creates and connects every layer
+// the layers are created in the order they were added, the hidden layers of the network are in the same order
+// followed by the relay layers that make every path into a layer the same length
+func (g *Graph) Build() (*Network, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	net := &Network{Sess: g.Sess}
+	layers := map[string]*Layer{}
+	for _, n := range g.nodes {
+		switch n.kind {
+		case NODE_INPUT:
+			input, err := NewInputLayer(n.name, n.conf, g.Sess, n.neurons)
+			if err != nil {
+				return nil, err
+			}
+			net.Input = input
+			layers[n.name] = input.Layer
+		case NODE_OUTPUT:
+			output, err := NewOutputLayer(n.name, n.conf, g.Sess, n.neurons)
+			if err != nil {
+				return nil, err
+			}
+			net.Output = output
+			layers[n.name] = output.Layer
+		default:
+			l := NewLayer(n.name, n.conf, g.Sess, n.neurons)
+			net.Layers = append(net.Layers, l)
+			layers[n.name] = l
+		}
+	}
+	// a layer is connected once every layer it depends on is, so the length of every path into it is known
+	for _, name := range g.order() {
+		var providers, residuals []*Layer
+		for _, e := range g.edges {
+			if e.consumer != name || e.delayed {
+				continue
+			}
+			if e.residual {
+				residuals = append(residuals, layers[e.provider])
+			} else {
+				providers = append(providers, layers[e.provider])
+			}
+		}
+		relays, err := layers[name].Concat(providers...)
+		if err != nil {
+			return nil, err
+		}
+		net.Layers = append(net.Layers, relays...)
+		for _, x := range residuals {
+			if relays, err = layers[name].Residual(x); err != nil {
+				return nil, err
+			}
+			net.Layers = append(net.Layers, relays...)
+		}
+	}
+	for _, e := range g.edges {
+		if !e.delayed {
+			continue
+		}
+		if err := ConnectRecurrent(layers[e.provider], layers[e.consumer], e.initial); err != nil {
+			return nil, err
+		}
+	}
+	return net, nil
+}
+
+// order returns the layers so every layer comes after the layers connected to it
+// ties are kept in the order the layers were added, the graph must not have cycles other than through delayed connections
+func (g *Graph) order() []string {
+	in := map[string]int{}
+	for _, e := range g.edges {
+		if !e.delayed {
+			in[e.consumer]++
+		}
+	}
+	var order []string
+	done := map[string]bool{}
+	for len(order) < len(g.nodes) {
+		for _, n := range g.nodes {
+			if done[n.name] || in[n.name] > 0 {
+				continue
+			}
+			done[n.name] = true
+			order = append(order, n.name)
+			for _, e := range g.edges {
+				if e.provider == n.name && !e.delayed {
+					in[e.consumer]--
+				}
+			}
+			break
+		}
+	}
+	return order
+}
@@ -0,0 +1,101 @@
+package neuron
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGraphBuild(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	// a diamond, the output gets the inputs through two different layers
+	net, err := NewGraph(sess).
+		Input("input", conf, 2).
+		Layer("left", conf, 3).
+		Layer("right", conf, 2).
+		Output("output", conf, 1).
+		Connect("input", "left").
+		Connect("input", "right").
+		Connect("left", "output").
+		Connect("right", "output").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Layers) != 2 || net.Layers[0].Name != "left" || net.Layers[1].Name != "right" {
+		t.Fatalf("unexpected hidden layers %v", net.Layers)
+	}
+	if got := len(net.Output.Layer.Neurons[0].Inputs); got != 5 {
+		t.Errorf("expected the output neuron to have 5 inputs, got %d", got)
+	}
+	net.On()
+	defer shutdown(t, sess)
+
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	dataset := []Sample{{X: []float64{0.1, 0.2}, Y: []float64{0.3}}, {X: []float64{0.4, -0.2}, Y: []float64{0.2}}}
+	if _, err := trainer.Fit(dataset, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trainer.Predict([]float64{0.1, 0.2}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphValidate(t *testing.T) {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	_, err := NewGraph(NewSession(0.001)).
+		Input("input", conf, 2).
+		Layer("a", conf, 2).
+		Layer("b", conf, 2).
+		Layer("loop1", conf, 2).
+		Layer("loop2", conf, 2).
+		Layer("dangling", conf, 2).
+		Layer("a", conf, 2).
+		Output("output", conf, 0).
+		Connect("input", "a").
+		Connect("a", "b").
+		Connect("b", "a").
+		Connect("b", "output").
+		Connect("loop1", "loop2").
+		Connect("loop2", "loop1").
+		Connect("a", "missing").
+		Connect("input", "a").
+		Build()
+	errs, ok := err.(GraphErrors)
+	if !ok {
+		t.Fatalf("expected GraphErrors, got %v", err)
+	}
+	fmt.Println(errs)
+
+	want := []string{
+		"a: duplicate layer name",
+		"output: layer has 0 neurons",
+		"a -> missing: unknown consumer \"missing\"",
+		"input -> a: duplicate connection",
+		"a -> b -> a: cycle",
+		"loop1 -> loop2 -> loop1: cycle",
+		"loop1: layer can not be reached from the input layer",
+		"loop2: layer can not be reached from the input layer",
+		"dangling: layer has no inputs",
+		"dangling: layer has no outputs",
+	}
+	got := map[string]bool{}
+	for _, e := range errs {
+		got[e.Error()] = true
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing problem %q", w)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("expected %d problems, got %d", len(want), len(errs))
+	}
+}
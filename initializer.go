@@ -0,0 +1,94 @@
+package neuron
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Initializer decides the initial weights of connections and biases of neurons
+type Initializer interface {
+	// Provider returns the values for a layer whose neurons have fanIn inputs and fanOut outputs each
+	// the values are drawn from rng so a seeded session builds the same network every time
+	Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider
+}
+
+// Zeros starts every value at zero
+type Zeros struct{}
+
+func (i *Zeros) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return &RandConstant{}
+}
+
+// Constant starts every value at Value
+type Constant struct {
+	Value float64
+}
+
+func (i *Constant) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return &RandConstant{Value: i.Value}
+}
+
+// Uniform draws values uniformly from [Min, Max)
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+func (i *Uniform) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return &RandUniform{Min: i.Min, Max: i.Max, Source: rng}
+}
+
+// Normal draws values from a normal distribution, unit normal if StdDev is zero
+type Normal struct {
+	Mean   float64
+	StdDev float64
+}
+
+func (i *Normal) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return &RandNormal{Mean: i.Mean, Scale: orDefault(i.StdDev, 1), Source: rng}
+}
+
+// Xavier (or Glorot) initialization keeps the variance of the values the same in both directions
+// it suits symmetric activators like Tanh and Sigmoid
+type Xavier struct {
+	Uniform bool // draw from a uniform instead of a normal distribution
+}
+
+func (i *Xavier) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return scaled(2/float64(fans(fanIn)+fans(fanOut)), i.Uniform, rng)
+}
+
+// He (or Kaiming) initialization keeps the variance of the values the same going forward
+// it suits the Relu family of activators
+type He struct {
+	Uniform bool // draw from a uniform instead of a normal distribution
+}
+
+func (i *He) Provider(fanIn, fanOut int, rng *rand.Rand) RandProvider {
+	return scaled(2/float64(fans(fanIn)), i.Uniform, rng)
+}
+
+// scaled draws values with zero mean and the given variance
+func scaled(variance float64, uniform bool, rng *rand.Rand) RandProvider {
+	if uniform {
+		limit := math.Sqrt(3 * variance)
+		return &RandUniform{Min: -limit, Max: limit, Source: rng}
+	}
+	return &RandNormal{Scale: math.Sqrt(variance), Source: rng}
+}
+
+// fans treats a neuron without connections as having one so the variance stays finite
+func fans(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// initializer returns i, or unit normal if it is nil
+func initializer(i Initializer) Initializer {
+	if i == nil {
+		return &Normal{}
+	}
+	return i
+}
@@ -0,0 +1,100 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// moments returns the mean and standard deviation of xs
+func moments(xs []float64) (float64, float64) {
+	var mean, sq float64
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)))
+}
+
+func TestInitializers(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     Initializer
+		mean     float64
+		stdDev   float64
+		min, max float64
+	}{
+		{"zeros", &Zeros{}, 0, 0, 0, 0},
+		{"constant", &Constant{Value: 0.1}, 0.1, 0, 0.1, 0.1},
+		{"uniform", &Uniform{Min: -1, Max: 3}, 1, 4 / math.Sqrt(12), -1, 3},
+		{"normal", &Normal{Mean: 2, StdDev: 0.5}, 2, 0.5, math.Inf(-1), math.Inf(1)},
+		{"xavier", &Xavier{}, 0, math.Sqrt(2.0 / 300), math.Inf(-1), math.Inf(1)},
+		{"xavier uniform", &Xavier{Uniform: true}, 0, math.Sqrt(2.0 / 300), -math.Sqrt(6.0 / 300), math.Sqrt(6.0 / 300)},
+		{"he", &He{}, 0, math.Sqrt(2.0 / 200), math.Inf(-1), math.Inf(1)},
+		{"he uniform", &He{Uniform: true}, 0, math.Sqrt(2.0 / 200), -math.Sqrt(6.0 / 200), math.Sqrt(6.0 / 200)},
+	}
+
+	for _, tt := range tests {
+		p := tt.init.Provider(200, 100, rand.New(rand.NewSource(1)))
+		xs := make([]float64, 20000)
+		for i := range xs {
+			xs[i] = p.RandNew()
+			if xs[i] < tt.min || xs[i] > tt.max {
+				t.Fatalf("%s: %.05f is outside [%.05f, %.05f]", tt.name, xs[i], tt.min, tt.max)
+			}
+		}
+		mean, stdDev := moments(xs)
+		fmt.Printf("%s: mean %.05f std dev %.05f\n", tt.name, mean, stdDev)
+		if math.Abs(mean-tt.mean) > 0.05*math.Max(tt.stdDev, 0.1) || math.Abs(stdDev-tt.stdDev) > 0.05*tt.stdDev+1e-9 {
+			t.Errorf("%s: got mean %.05f std dev %.05f want %.05f and %.05f", tt.name, mean, stdDev, tt.mean, tt.stdDev)
+		}
+	}
+}
+
+func TestLayerInitializer(t *testing.T) {
+	conf := &Config{
+		Precision:       0.0001,
+		Activator:       &Tanh{},
+		PreProcessor:    &SumPreProcessor{},
+		Initializer:     &Xavier{},
+		BiasInitializer: &Zeros{},
+	}
+	sess := NewSession(0.001).SetSeed(1)
+	provider := NewLayer("provider", conf, sess, 200)
+	consumer := NewLayer("consumer", conf, sess, 100)
+	consumer.Initializer = &Constant{Value: 0.5}
+	if err := ConnectLayers(provider, consumer); err != nil {
+		panic(err)
+	}
+	last := NewLayer("last", conf, sess, 100)
+	if err := ConnectLayers(consumer, last); err != nil {
+		panic(err)
+	}
+
+	for _, n := range provider.Neurons {
+		if n.bias != 0 {
+			t.Fatalf("expected a zero bias, got %.05f", n.bias)
+		}
+	}
+	for _, n := range consumer.Neurons {
+		for _, conn := range n.Inputs {
+			if conn.Weight != 0.5 {
+				t.Fatalf("the layer initializer was not used, got weight %.05f", conn.Weight)
+			}
+		}
+	}
+	var weights []float64
+	for _, n := range last.Neurons {
+		for _, conn := range n.Inputs {
+			weights = append(weights, conn.Weight)
+		}
+	}
+	want := math.Sqrt(2.0 / 200)
+	if _, stdDev := moments(weights); math.Abs(stdDev-want) > 0.05*want {
+		t.Errorf("got weights with std dev %.05f want %.05f", stdDev, want)
+	}
+}
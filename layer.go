@@ -1,23 +1,20 @@
 package neuron
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"sync"
 )
 
-func ScaledRand() float64 {
-	//r := rand.NormFloat64() * math.Abs(rand.NormFloat64())
-	r := rand.NormFloat64()
-	fmt.Printf("%.05f\n", r)
-	return r
-}
-
 // Layer is a simple layer structure that contains a single rank of Neurons
 type Layer struct {
 	Name    string
 	Config  *Config
 	Sess    *Session
 	Neurons []*Neuron
+	// Initializer draws the weights of the connections made into the layer by ConnectLayers
+	// NewLayer sets it to Config.Initializer
+	Initializer Initializer
 }
 
 func (l *Layer) On() {
@@ -32,27 +29,60 @@ func NewLayer(name string, conf *Config, sess *Session, neurons int) *Layer {
 		Config:  conf,
 		Sess:    sess,
 		Neurons: make([]*Neuron, neurons),
+		Initializer: conf.Initializer,
 	}
 
+	biases := initializer(conf.BiasInitializer).Provider(0, 0, sess.Rand())
 	ids := sess.NextIDs(neurons)
 	for i := 0; i < neurons; i++ {
-		l.Neurons[i] = NewNeuron(conf, ids[i], ScaledRand(), ScaledRand(), sess)
+		l.Neurons[i] = NewNeuron(conf, ids[i], biases.RandNew(), sess)
 	}
 
 	return l
 }
 
  func ConnectLayers(provider, consumer *Layer) error {
- 	if provider.Sess != consumer.Sess {
- 		return fmt.Errorf("groups must be part of the same session")
-	}
-	return ConnectNeurons(provider.Neurons, consumer.Neurons)
+	return ConnectLayersWith(provider, consumer, nil)
  }
 
+// ConnectLayersWith connects the neurons of provider to the neurons of consumer chosen by c
+// every neuron to every neuron if c is nil
+func ConnectLayersWith(provider, consumer *Layer, c Connectivity) error {
+	if provider.Sess != consumer.Sess {
+		return fmt.Errorf("groups must be part of the same session")
+	}
+	return connectNeurons(provider.Neurons, consumer.Neurons, consumer.Initializer, c)
+}
+
+// ConnectRecurrent connects every neuron of provider to every neuron of consumer through delayed connections
+// so the consumer gets the provider's outputs of the previous time step, initial in the first one
+// a layer connected to itself makes an Elman network, an output layer connected to a hidden layer a Jordan network
+func ConnectRecurrent(provider, consumer *Layer, initial float64) error {
+	if provider.Sess != consumer.Sess {
+		return fmt.Errorf("groups must be part of the same session")
+	}
+	weights := initializer(consumer.Initializer).Provider(len(provider.Neurons), len(consumer.Neurons), consumer.Sess.Rand())
+	for _, p := range provider.Neurons {
+		for _, c := range consumer.Neurons {
+			conn := NewDelayedConnection(p.ID(), c.ID(), initial)
+			conn.Weight = weights.RandNew()
+			if err := c.AddInputConnections([]*Connection{conn}); err != nil {
+				return err
+			}
+			if err := p.AddOutputConnections([]*Connection{conn}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 
 type InputLayer struct {
 	Layer  *Layer
 	Inputs []*Connection
+	busy   chan struct{} // held while a sample is being sent, so samples are never interleaved
+	once   sync.Once
 }
 
 func NewInputLayer(name string, conf *Config, sess *Session, neurons int) (*InputLayer, error) {
@@ -61,8 +91,11 @@ func NewInputLayer(name string, conf *Config, sess *Session, neurons int) (*Inpu
 		Layer:  layer,
 		Inputs: make([]*Connection, len(layer.Neurons)),
 	}
+	// every neuron of an input layer has a single input
+	weights := initializer(layer.Initializer).Provider(1, 1, sess.Rand())
 	for i, n := range layer.Neurons {
 		il.Inputs[i] = NewConnection(nil, n.ID())
+		il.Inputs[i].Weight = weights.RandNew()
 		if err := n.AddInputConnections([]*Connection{il.Inputs[i]}); err != nil {
 			return nil, err
 		}
@@ -71,18 +104,73 @@ func NewInputLayer(name string, conf *Config, sess *Session, neurons int) (*Inpu
 }
 
 func (l *InputLayer) Forward(packets ...*Packet) error{
+	return l.ForwardContext(context.Background(), packets...)
+}
+
+// ForwardContext sends a packet to every input of the layer
+// if ctx is done before every packet is sent it returns ctx.Err() and sends the rest in the background,
+// so the network stays in step and the sample still comes out of the output layer
+func (l *InputLayer) ForwardContext(ctx context.Context, packets ...*Packet) error {
 	if len(packets) != len(l.Inputs) {
 		return fmt.Errorf("packet count must equal input count")
 	}
+	stopped := l.Layer.Sess.Ctx()
+	// the mode is fixed when the sample enters the network, so a sample finished in the background
+	// after the mode changed is not mistaken for one that will get a backward pass
+	mode := l.Layer.Sess.Mode()
+	stamped := make([]*Packet, len(packets))
+	for i, p := range packets {
+		stamped[i] = &Packet{NeuronID: p.NeuronID, SampleID: p.SampleID, Mode: mode, X: p.X}
+		if p.Mode != MODE_OFF {
+			stamped[i].Mode = p.Mode
+		}
+	}
+	packets = stamped
+	l.once.Do(func() { l.busy = make(chan struct{}, 1) })
+	select {
+	case l.busy <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-stopped.Done():
+		return stopped.Err()
+	}
 	for i, in := range l.Inputs {
-		in.Forward <- packets[i]
+		select {
+		case in.Forward <- packets[i]:
+		case <-ctx.Done():
+			go l.finish(stopped, packets, i)
+			return ctx.Err()
+		case <-stopped.Done():
+			<-l.busy
+			return stopped.Err()
+		}
 	}
+	<-l.busy
 	return nil
 }
 
+// finish sends the packets from index i on, unless the session stops
+func (l *InputLayer) finish(stopped context.Context, packets []*Packet, i int) {
+	defer func() { <-l.busy }()
+	for ; i < len(l.Inputs); i++ {
+		select {
+		case l.Inputs[i].Forward <- packets[i]:
+		case <-stopped.Done():
+			return
+		}
+	}
+}
+
 type OutputLayer struct {
 	Layer   *Layer
 	Outputs []*Connection
+	Cost    Cost // used by BackwardCost, MeanSquaredError by default
+	// Softmax turns the outputs into a probability distribution, pair it with CategoricalCrossEntropy
+	// and an Identity activator so the neurons produce the logits
+	Softmax bool
+	skip    map[int]int     // number of packets to throw away on each output, left over from abandoned reads
+	discard map[uint64]bool // samples whose outputs are thrown away
+	mu      sync.Mutex
 }
 
 func NewOutputLayer(name string, conf *Config, sess *Session, neurons int) (*OutputLayer, error) {
@@ -90,6 +178,7 @@ func NewOutputLayer(name string, conf *Config, sess *Session, neurons int) (*Out
 	ol := &OutputLayer{
 		Layer:  layer,
 		Outputs: make([]*Connection, len(layer.Neurons)),
+		Cost:    &MeanSquaredError{},
 	}
 	for i, n := range layer.Neurons {
 		ol.Outputs[i] = NewConnection(n.ID(), nil)
@@ -100,10 +189,175 @@ func NewOutputLayer(name string, conf *Config, sess *Session, neurons int) (*Out
 	return ol, nil
 }
 
+// Forward waits for a packet from every output
+// if the session stops first it returns an empty slice, so check the length before indexing the result,
+// or use ForwardContext to get the error
 func (g *OutputLayer) Forward() []*Packet {
-	packets := make([]*Packet, len(g.Outputs))
-	for i, in := range g.Outputs {
-		packets[i] = <- in.Forward
+	packets, err := g.ForwardContext(context.Background())
+	if err != nil {
+		return []*Packet{}
 	}
 	return packets
-}
\ No newline at end of file
+}
+
+// ForwardContext waits for a packet from every output
+// if ctx is done first it returns ctx.Err(), and the outputs that were not read yet are thrown away by the next call
+func (g *OutputLayer) ForwardContext(ctx context.Context) ([]*Packet, error) {
+	stopped := g.Layer.Sess.Ctx()
+	read := func(i int) (*Packet, error) {
+		select {
+		case p := <-g.Outputs[i].Forward:
+			return p, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-stopped.Done():
+			return nil, stopped.Err()
+		}
+	}
+
+	// first catch up on the outputs of samples that were abandoned part way through,
+	// one packet per output at a time so no output gets ahead of the network
+	for lagging := true; lagging; {
+		lagging = false
+		for i := range g.Outputs {
+			if g.lag(i) == 0 {
+				continue
+			}
+			if _, err := read(i); err != nil {
+				return nil, err
+			}
+			g.caughtUp(i)
+			lagging = true
+		}
+	}
+
+	var packets []*Packet
+	for packets == nil {
+		packets = make([]*Packet, len(g.Outputs))
+		for i := range g.Outputs {
+			p, err := read(i)
+			if err != nil {
+				g.abandon(packets)
+				return nil, err
+			}
+			packets[i] = p
+		}
+		if g.unwanted(packets[0].SampleID) {
+			packets = nil
+		}
+	}
+	if g.Softmax {
+		xs := make([]float64, len(packets))
+		for i, p := range packets {
+			xs[i] = p.X
+		}
+		for i, prob := range Softmax(xs) {
+			packets[i] = &Packet{NeuronID: packets[i].NeuronID, SampleID: packets[i].SampleID, Mode: packets[i].Mode, X: prob}
+		}
+	}
+	return packets, nil
+}
+
+// Discard throws away the outputs of sample id when they arrive
+// samples come out in the order they were sent, so a sample that never arrives is forgotten once a later one does
+func (g *OutputLayer) Discard(id uint64) {
+	if id == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.discard == nil {
+		g.discard = map[uint64]bool{}
+	}
+	g.discard[id] = true
+}
+
+// abandon makes the next read throw away the rest of a partially read sample
+func (g *OutputLayer) abandon(packets []*Packet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if packets[0] == nil {
+		return
+	}
+	if g.skip == nil {
+		g.skip = map[int]int{}
+	}
+	for i, p := range packets {
+		if p == nil {
+			g.skip[i]++
+		}
+	}
+}
+
+// lag returns the number of packets of abandoned samples still waiting on output i
+func (g *OutputLayer) lag(i int) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.skip[i]
+}
+
+func (g *OutputLayer) caughtUp(i int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.skip[i]--
+}
+
+// unwanted reports whether the outputs of sample id should be thrown away
+func (g *OutputLayer) unwanted(id uint64) bool {
+	if id == 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	unwanted := g.discard[id]
+	for abandoned := range g.discard {
+		if abandoned <= id {
+			delete(g.discard, abandoned)
+		}
+	}
+	return unwanted
+}
+
+// Backward sends the gradient of the loss with respect to each output back through the network
+// it returns the context's error if the session stops
+func (g *OutputLayer) Backward(packets ...*Packet) error {
+	if len(packets) != len(g.Outputs) {
+		return fmt.Errorf("packet count must equal output count")
+	}
+	stopped := g.Layer.Sess.Ctx()
+	for i, out := range g.Outputs {
+		select {
+		case out.Backward <- packets[i]:
+		case <-stopped.Done():
+			return stopped.Err()
+		}
+	}
+	return nil
+}
+
+// BackwardCost computes the cost of outputs against targets and sends its gradients back through the network
+func (g *OutputLayer) BackwardCost(outputs []*Packet, targets []float64) (float64, error) {
+	predictions := make([]float64, len(outputs))
+	for i, p := range outputs {
+		predictions[i] = p.X
+	}
+	loss, grads, err := g.Cost.Cost(predictions, targets)
+	if err != nil {
+		return 0, err
+	}
+	if g.Softmax {
+		// the neurons need the gradient with respect to the logits, not the probabilities
+		var dot float64
+		for i, p := range predictions {
+			dot += grads[i] * p
+		}
+		for i, p := range predictions {
+			grads[i] = p * (grads[i] - dot)
+		}
+	}
+	packets := make([]*Packet, len(grads))
+	for i, grad := range grads {
+		packets[i] = &Packet{X: grad}
+	}
+	return loss, g.Backward(packets...)
+}
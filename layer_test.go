@@ -50,59 +50,46 @@ func TestLayer(t *testing.T) {
 
 	println("all neurons are running")
 
-	EPOCHS := 1000
+	EPOCHS := 10
 	DATASET_SIZE := 100
 
 	// make a dummy dataset
-	dataset := make([][]float64, DATASET_SIZE)
+	dataset := make([]Sample, DATASET_SIZE)
 	for i := 0; i < DATASET_SIZE; i++ {
 		x := rand.Float64()
-		dataset[i] = []float64{x, simpleQuadratic(x)}
+		dataset[i] = Sample{X: []float64{x}, Y: []float64{simpleQuadratic(x)}}
 	}
 
-	for i := 0; i < EPOCHS; i++ {
+	sess.SetScheduler(&OneCycle{Min: 1e-7, Max: 1e-5, Steps: EPOCHS}, SCHEDULE_EPOCH)
+	trainer := NewTrainer(sess, input, output)
+	defer shutdown(t, sess)
 
-		targets := make([]float64, DATASET_SIZE)
-		predictions := make([]float64, DATASET_SIZE)
-		costs := make([]float64, DATASET_SIZE)
-		var costSum, pSum, tSum float64
+	startLoss, err := trainer.Evaluate(dataset)
+	if err != nil {
+		panic(err)
+	}
 
+	for i := 0; i < EPOCHS; i++ {
 		perComplete := float64(i) / float64(EPOCHS)
-		sess.SetLearningRate(OneCycleLearningRate(0.001, 0.1, perComplete))
-		// loop over the dataset
-		sess.SetMode(MODE_PREDICTING)
-		for i, data := range dataset {
-			x := data[0]
-			y := data[1]
-			if err := input.Forward(&Packet{
-				NeuronID: nil,
-				X:        x,
-			}); err != nil {
-				panic(err)
-			}
-			out := output.Forward()[0] // blocking until we have an output
-			cost := out.X - y
-			costs[i] = cost
-			predictions[i] = out.X
-			targets[i] = y
-			costSum += cost
-			pSum += out.X
-			tSum += y
-		}
-
-		// compute the average error
-		avgCost := costSum / float64(DATASET_SIZE)
-		avgP := pSum / float64(DATASET_SIZE)
-		avgT := tSum / float64(DATASET_SIZE)
-		// get the mean absolute error
-		sess.SetMode(MODE_TRAINING).SetLoss((avgP - avgT) / neuronCnt)
-
-		if err := input.Forward(&Packet{}); err != nil {
+		lr := sess.LearningRate()
+		loss, err := trainer.Fit(dataset, 1)
+		if err != nil {
 			panic(err)
 		}
-		// empty the queue
-		output.Forward()
-		fmt.Printf("EPOCH: %d [%.04f%%] LR: %.04f TARGET: %.03f PRED: %.03f ERROR: %.03f\n",
-			i+1, perComplete * 100, sess.LearningRate(), avgT, avgP, avgCost)
+		fmt.Printf("EPOCH: %d [%.04f%%] LR: %.03g LOSS: %.03f\n", i+1, perComplete*100, lr, loss)
+	}
+
+	endLoss, err := trainer.Evaluate(dataset)
+	if err != nil {
+		panic(err)
+	}
+	x := rand.Float64()
+	yhat, err := trainer.Predict([]float64{x})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("LOSS BEFORE: %.03f AFTER: %.03f TARGET: %.03f PRED: %.03f\n", startLoss, endLoss, simpleQuadratic(x), yhat[0])
+	if endLoss >= startLoss {
+		t.Errorf("loss did not improve: before %.03f after %.03f", startLoss, endLoss)
 	}
 }
@@ -0,0 +1,441 @@
+package neuron
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+type Format string
+
+const (
+	FORMAT_JSON = Format("json") // human readable
+	FORMAT_GOB  = Format("gob")  // compact
+)
+
+// Network is a set of layers that can be saved and loaded as a whole
+type Network struct {
+	Sess   *Session
+	Input  *InputLayer
+	Layers []*Layer // hidden layers
+	Output *OutputLayer
+}
+
+// Model is the serialized form of a Network
+type Model struct {
+	NeuronIDCur  uint64
+	LearningRate float64
+	Seed         int64
+	Input        LayerModel
+	Layers       []LayerModel
+	Output       LayerModel
+	Cost         *ComponentModel
+	Softmax      bool
+}
+
+type LayerModel struct {
+	Name    string
+	Config  ConfigModel
+	Neurons []NeuronModel
+}
+
+type NeuronModel struct {
+	ID        NeuronID
+	Bias      float64
+	Inputs    []ConnectionModel
+	Activator string `json:",omitempty"` // spec of a learnable activator with the neuron's own parameters
+}
+
+type ConnectionModel struct {
+	Provider NeuronID // 0 for the inputs of an input layer
+	Weight   float64
+	Frozen   bool    `json:",omitempty"`
+	Delayed  bool    `json:",omitempty"`
+	Initial  float64 `json:",omitempty"` // value of a delayed connection in the first time step
+}
+
+type ConfigModel struct {
+	Precision       float64
+	RandomFactor    float64
+	NumSignals      int
+	MaxWeight       float64
+	MaxBias         float64
+	Frozen          bool   `json:",omitempty"`
+	Activator       string // spec from the registry, like "leaky_relu(alpha=0.5)"
+	PreProcessor    string // spec from the registry, like "sum"
+	Optimizer       *ComponentModel
+	Initializer     *ComponentModel `json:",omitempty"`
+	BiasInitializer *ComponentModel `json:",omitempty"`
+}
+
+// ComponentModel is an Optimizer, Cost or Initializer stored as its type name and exported fields
+type ComponentModel struct {
+	Type   string
+	Params json.RawMessage
+}
+
+// componentTypes creates the components that can be loaded
+var componentTypes = map[string]func() interface{}{
+	"SGD":                     func() interface{} { return &SGD{} },
+	"Momentum":                func() interface{} { return &Momentum{} },
+	"RMSProp":                 func() interface{} { return &RMSProp{} },
+	"Adam":                    func() interface{} { return &Adam{} },
+	"AdamW":                   func() interface{} { return &AdamW{} },
+	"MeanAbsoluteError":       func() interface{} { return &MeanAbsoluteError{} },
+	"MeanSquaredError":        func() interface{} { return &MeanSquaredError{} },
+	"Huber":                   func() interface{} { return &Huber{} },
+	"LogCosh":                 func() interface{} { return &LogCosh{} },
+	"BinaryCrossEntropy":      func() interface{} { return &BinaryCrossEntropy{} },
+	"CategoricalCrossEntropy": func() interface{} { return &CategoricalCrossEntropy{} },
+	"Hinge":                   func() interface{} { return &Hinge{} },
+	"Zeros":                   func() interface{} { return &Zeros{} },
+	"Constant":                func() interface{} { return &Constant{} },
+	"Uniform":                 func() interface{} { return &Uniform{} },
+	"Normal":                  func() interface{} { return &Normal{} },
+	"Xavier":                  func() interface{} { return &Xavier{} },
+	"He":                      func() interface{} { return &He{} },
+}
+
+func encodeComponent(c interface{}) (*ComponentModel, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if v := reflect.ValueOf(c); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	t := reflect.TypeOf(c)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := componentTypes[t.Name()]; !ok {
+		return nil, fmt.Errorf("cannot save component of type %s", t)
+	}
+	params, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return &ComponentModel{Type: t.Name(), Params: params}, nil
+}
+
+func decodeComponent(m *ComponentModel) (interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	newComponent, ok := componentTypes[m.Type]
+	if !ok {
+		return nil, fmt.Errorf("cannot load component of type %s", m.Type)
+	}
+	c := newComponent()
+	if err := json.Unmarshal(m.Params, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func encodeConfig(conf *Config) (ConfigModel, error) {
+	m := ConfigModel{
+		Precision:    conf.Precision,
+		RandomFactor: conf.RandomFactor,
+		NumSignals:   conf.NumSignals,
+		MaxWeight:    conf.MaxWeight,
+		MaxBias:      conf.MaxBias,
+		Frozen:       conf.Frozen,
+	}
+	var err error
+	if conf.Activator != nil {
+		if m.Activator, err = SpecOf(conf.Activator); err != nil {
+			return m, err
+		}
+	}
+	if conf.PreProcessor != nil {
+		if m.PreProcessor, err = SpecOf(conf.PreProcessor); err != nil {
+			return m, err
+		}
+	}
+	if m.Optimizer, err = encodeComponent(conf.Optimizer); err != nil {
+		return m, err
+	}
+	if m.Initializer, err = encodeComponent(conf.Initializer); err != nil {
+		return m, err
+	}
+	if m.BiasInitializer, err = encodeComponent(conf.BiasInitializer); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func decodeConfig(m ConfigModel) (*Config, error) {
+	conf := &Config{
+		Precision:    m.Precision,
+		RandomFactor: m.RandomFactor,
+		NumSignals:   m.NumSignals,
+		MaxWeight:    m.MaxWeight,
+		MaxBias:      m.MaxBias,
+		Frozen:       m.Frozen,
+	}
+	var err error
+	if m.Activator != "" {
+		if conf.Activator, err = NewActivator(m.Activator); err != nil {
+			return nil, err
+		}
+	}
+	if m.PreProcessor != "" {
+		if conf.PreProcessor, err = NewPreProcessor(m.PreProcessor); err != nil {
+			return nil, err
+		}
+	}
+	c, err := decodeComponent(m.Optimizer)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		if conf.Optimizer, _ = c.(Optimizer); conf.Optimizer == nil {
+			return nil, fmt.Errorf("%s is not an Optimizer", m.Optimizer.Type)
+		}
+	}
+	if conf.Initializer, err = decodeInitializer(m.Initializer); err != nil {
+		return nil, err
+	}
+	if conf.BiasInitializer, err = decodeInitializer(m.BiasInitializer); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func decodeInitializer(m *ComponentModel) (Initializer, error) {
+	c, err := decodeComponent(m)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	i, ok := c.(Initializer)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Initializer", m.Type)
+	}
+	return i, nil
+}
+
+func encodeLayer(l *Layer) (LayerModel, error) {
+	conf, err := encodeConfig(l.Config)
+	if err != nil {
+		return LayerModel{}, fmt.Errorf("layer %s: %v", l.Name, err)
+	}
+	m := LayerModel{
+		Name:    l.Name,
+		Config:  conf,
+		Neurons: make([]NeuronModel, len(l.Neurons)),
+	}
+	for i, n := range l.Neurons {
+		n.mu.Lock()
+		nm := NeuronModel{
+			ID:     n.id,
+			Bias:   n.bias,
+			Inputs: make([]ConnectionModel, len(n.Inputs)),
+		}
+		for j, conn := range n.Inputs {
+			if conn.ProvidingNeuron != nil {
+				nm.Inputs[j].Provider = *conn.ProvidingNeuron
+			}
+			nm.Inputs[j].Weight = conn.Weight
+			nm.Inputs[j].Frozen = conn.Frozen
+			nm.Inputs[j].Delayed = conn.Delayed
+			nm.Inputs[j].Initial = conn.Initial
+		}
+		act := n.act
+		n.mu.Unlock()
+		if _, ok := act.(LearnableActivator); ok {
+			if nm.Activator, err = SpecOf(act); err != nil {
+				return LayerModel{}, fmt.Errorf("layer %s: %v", l.Name, err)
+			}
+		}
+		m.Neurons[i] = nm
+	}
+	return m, nil
+}
+
+// Encode turns the network into a Model
+func (net *Network) Encode() (*Model, error) {
+	net.Sess.mu.RLock()
+	m := &Model{
+		NeuronIDCur:  net.Sess.neuronIDcur,
+		LearningRate: net.Sess.learningRate,
+		Seed:         net.Sess.seed,
+		Layers:       make([]LayerModel, len(net.Layers)),
+	}
+	net.Sess.mu.RUnlock()
+
+	var err error
+	if m.Input, err = encodeLayer(net.Input.Layer); err != nil {
+		return nil, err
+	}
+	for i, l := range net.Layers {
+		if m.Layers[i], err = encodeLayer(l); err != nil {
+			return nil, err
+		}
+	}
+	if m.Output, err = encodeLayer(net.Output.Layer); err != nil {
+		return nil, err
+	}
+	if m.Cost, err = encodeComponent(net.Output.Cost); err != nil {
+		return nil, err
+	}
+	m.Softmax = net.Output.Softmax
+	return m, nil
+}
+
+// Decode rebuilds the network described by the model in a new session
+// the neurons are not turned on
+func (m *Model) Decode() (*Network, error) {
+	sess := NewSession(m.LearningRate).SetSeed(m.Seed)
+	sess.neuronIDcur = m.NeuronIDCur
+	neurons := map[NeuronID]*Neuron{}
+
+	// create every neuron before connecting them, a provider can be in any layer
+	decodeLayer := func(lm LayerModel) (*Layer, error) {
+		conf, err := decodeConfig(lm.Config)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %v", lm.Name, err)
+		}
+		l := &Layer{
+			Name:        lm.Name,
+			Config:      conf,
+			Sess:        sess,
+			Neurons:     make([]*Neuron, len(lm.Neurons)),
+			Initializer: conf.Initializer,
+		}
+		for i, nm := range lm.Neurons {
+			if _, ok := neurons[nm.ID]; ok {
+				return nil, fmt.Errorf("layer %s: duplicate neuron %d", lm.Name, nm.ID)
+			}
+			l.Neurons[i] = NewNeuron(conf, nm.ID, nm.Bias, sess)
+			if nm.Activator != "" {
+				act, err := NewActivator(nm.Activator)
+				if err != nil {
+					return nil, fmt.Errorf("layer %s: %v", lm.Name, err)
+				}
+				l.Neurons[i].SetActivator(act)
+			}
+			neurons[nm.ID] = l.Neurons[i]
+		}
+		return l, nil
+	}
+
+	net := &Network{
+		Sess:   sess,
+		Input:  &InputLayer{},
+		Layers: make([]*Layer, len(m.Layers)),
+		Output: &OutputLayer{Cost: &MeanSquaredError{}, Softmax: m.Softmax},
+	}
+	var err error
+	if net.Input.Layer, err = decodeLayer(m.Input); err != nil {
+		return nil, err
+	}
+	for i, lm := range m.Layers {
+		if net.Layers[i], err = decodeLayer(lm); err != nil {
+			return nil, err
+		}
+	}
+	if net.Output.Layer, err = decodeLayer(m.Output); err != nil {
+		return nil, err
+	}
+	if m.Cost != nil {
+		c, err := decodeComponent(m.Cost)
+		if err != nil {
+			return nil, err
+		}
+		if net.Output.Cost, _ = c.(Cost); net.Output.Cost == nil {
+			return nil, fmt.Errorf("%s is not a Cost", m.Cost.Type)
+		}
+	}
+
+	// connect the neurons
+	for i, lm := range append(append([]LayerModel{m.Input}, m.Layers...), m.Output) {
+		for _, nm := range lm.Neurons {
+			consumer := neurons[nm.ID]
+			for _, cm := range nm.Inputs {
+				if cm.Provider == 0 {
+					if i != 0 {
+						return nil, fmt.Errorf("neuron %d of layer %s has an input without a provider", nm.ID, lm.Name)
+					}
+					conn := NewConnection(nil, consumer.ID())
+					conn.Weight = cm.Weight
+					conn.Frozen = cm.Frozen
+					net.Input.Inputs = append(net.Input.Inputs, conn)
+					if err := consumer.AddInputConnections([]*Connection{conn}); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				provider, ok := neurons[cm.Provider]
+				if !ok {
+					return nil, fmt.Errorf("neuron %d is connected to unknown neuron %d", nm.ID, cm.Provider)
+				}
+				conn := NewConnection(provider.ID(), consumer.ID())
+				if cm.Delayed {
+					conn = NewDelayedConnection(provider.ID(), consumer.ID(), cm.Initial)
+				}
+				conn.Weight = cm.Weight
+				conn.Frozen = cm.Frozen
+				if err := provider.AddOutputConnections([]*Connection{conn}); err != nil {
+					return nil, err
+				}
+				if err := consumer.AddInputConnections([]*Connection{conn}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, n := range net.Output.Layer.Neurons {
+		conn := NewConnection(n.ID(), nil)
+		net.Output.Outputs = append(net.Output.Outputs, conn)
+		if err := n.AddOutputConnections([]*Connection{conn}); err != nil {
+			return nil, err
+		}
+	}
+	return net, nil
+}
+
+// On turns on every neuron of the network
+func (net *Network) On() {
+	net.Input.Layer.On()
+	for _, l := range net.Layers {
+		l.On()
+	}
+	net.Output.Layer.On()
+}
+
+// Save writes the network to w in the given format
+func Save(w io.Writer, format Format, net *Network) error {
+	m, err := net.Encode()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	case FORMAT_GOB:
+		return gob.NewEncoder(w).Encode(m)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Load reads a network saved in the given format from r
+func Load(r io.Reader, format Format) (*Network, error) {
+	m := &Model{}
+	switch format {
+	case FORMAT_JSON:
+		if err := json.NewDecoder(r).Decode(m); err != nil {
+			return nil, err
+		}
+	case FORMAT_GOB:
+		if err := gob.NewDecoder(r).Decode(m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return m.Decode()
+}
@@ -0,0 +1,88 @@
+package neuron
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &LeakyRelu{0.5},
+		PreProcessor: &SumPreProcessor{},
+		MaxWeight:    5,
+		Optimizer:    &Adam{Beta1: 0.8},
+		Initializer:  &He{Uniform: true},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 4)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	output.Cost = &Huber{Delta: 2}
+	net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+
+	trainer := NewTrainer(sess, input, output)
+	dataset := make([]Sample, 20)
+	for i := range dataset {
+		x1, x2 := rand.Float64(), rand.Float64()
+		dataset[i] = Sample{X: []float64{x1, x2}, Y: []float64{x1 - 2*x2}}
+	}
+	if _, err := trainer.Fit(dataset, 3); err != nil {
+		t.Fatal(err)
+	}
+	want, err := trainer.Evaluate(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{FORMAT_JSON, FORMAT_GOB} {
+		var buf bytes.Buffer
+		if err := Save(&buf, format, net); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded.On()
+
+		if got := loaded.Output.Cost.(*Huber).Delta; got != 2 {
+			t.Errorf("%s: cost was not restored, got delta %.1f", format, got)
+		}
+		if got := loaded.Layers[0].Config.Activator.(*LeakyRelu).Alpha; got != 0.5 {
+			t.Errorf("%s: activator was not restored, got alpha %.1f", format, got)
+		}
+		if got := loaded.Layers[0].Config.Optimizer.(*Adam).Beta1; got != 0.8 {
+			t.Errorf("%s: optimizer was not restored, got beta1 %.1f", format, got)
+		}
+		if got, ok := loaded.Layers[0].Config.Initializer.(*He); !ok || !got.Uniform {
+			t.Errorf("%s: initializer was not restored, got %#v", format, loaded.Layers[0].Config.Initializer)
+		}
+		if got := loaded.Sess.NextIDs(1)[0]; got != sess.NextIDs(1)[0] {
+			t.Errorf("%s: neuron ids were not restored, got next id %d", format, got)
+		}
+		got, err := NewTrainer(loaded.Sess, loaded.Input, loaded.Output).Evaluate(dataset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: loaded network has loss %.06f want %.06f", format, got, want)
+		}
+		shutdown(t, loaded.Sess)
+	}
+}
@@ -15,11 +15,14 @@ type Neuron struct {
 	Inputs  []*Connection
 	Outputs []*Connection
 	cache   *NeuronCache
-	weight  float64
 	bias    float64
+	bState  OptimizerState
 	session *Session
 	mu      sync.Mutex
 	pre     PreProcessor
+	act     Activator        // the neuron's own copy if the configured activator is learnable
+	aStates []OptimizerState // optimizer state of each parameter of a learnable activator
+	rng     *rand.Rand
 	alive   bool
 }
 
@@ -27,85 +30,238 @@ func (n *Neuron) ID() *NeuronID {
 	return &n.id
 }
 
-func (n *Neuron) Forward() {
-	//inputsExpected := len(n.Inputs)
-	packets := map[*NeuronID]*Packet{}
+// input is a packet received by a neuron together with the connection it arrived on
+type input struct {
+	conn   *Connection
+	packet *Packet
+}
+
+// receive waits for a packet on every input connection and returns them in the order of n.Inputs
+// the connection identifies the sender, so packets without a NeuronID can not be mixed up
+// delayed inputs are read after the others, so they keep the value of the last time step until the next one starts
+func (n *Neuron) receive() ([]input, error) {
+	done := n.session.ctx.Done()
+	inputs := make([]input, len(n.Inputs))
+	for _, delayed := range []bool{false, true} {
+		for i, conn := range n.Inputs {
+			if conn.Delayed != delayed {
+				continue
+			}
+			select {
+			case packet := <-conn.Forward:
+				inputs[i] = input{conn: conn, packet: packet}
+			case <-done:
+				return nil, n.session.ctx.Err()
+			}
+		}
+	}
+	return inputs, nil
+}
 
+// Forward waits for a packet from every input and sends the activation to every output
+// it returns the context's error if the session stops while it is waiting
+func (n *Neuron) Forward() error {
+	done := n.session.ctx.Done()
 	var (
-		x  float64
-		xs []float64
+		x      float64
+		z      float64
+		sample uint64
+		xs     = make([]float64, len(n.Inputs)) // the raw inputs in the order of n.Inputs
+		ws     = make([]float64, len(n.Inputs)) // the weights of the inputs
 	)
 
-	for _, conn := range n.Inputs {
-		packet := <-conn.Forward
-		packets[packet.NeuronID] = packet
+	inputs, err := n.receive()
+	if err != nil {
+		return err
 	}
-
-	if n.session.Predicting() { // we are predicting
-		xs = make([]float64, len(packets))
-		i := 0
-		for _, p := range packets {
-			xs[i] = p.X
-			i++
+	for i, in := range inputs {
+		xs[i] = in.packet.X
+	}
+	// every connection delivers the samples in the order they were sent, so the inputs belong to the same sample
+	// a delayed input belongs to the sample of the previous time step
+	var mode Mode
+	for _, in := range inputs {
+		if !in.conn.Delayed {
+			sample, mode = in.packet.SampleID, in.packet.Mode
+			break
 		}
-		x = n.Conf.PreProcessor.PreProcess(xs)
-		z := x*n.weight + n.bias
-		a := n.Conf.Activator.Forward(z)
-		n.cache.Add(x, z, a)
-		x = a
-		//fmt.Printf(">+< %v x = %.3f, z = %.3f\n", n.ID(), x, z)
 	}
-	if n.session.Training() { // we are updating weights and biases
-		// update weights and bias if we were passed a loss value
-		n.UpdateWeightAndBias()
+	// the mode the sample was sent in decides if it is trained on, the session's mode if it was not sent through an input layer
+	training := mode.Training()
+	if mode == MODE_OFF {
+		training = n.session.Training()
+	}
+
+	n.mu.Lock()
+	for i, in := range inputs {
+		ws[i] = in.conn.Weight
+	}
+	if wp, ok := n.Conf.PreProcessor.(WeightedPreProcessor); ok {
+		z = wp.PreProcessWeighted(xs, ws) + n.bias
+	} else {
+		z = n.Conf.PreProcessor.PreProcess(weigh(xs, ws)) + n.bias
+	}
+	a := n.act.Forward(z)
+	n.mu.Unlock()
+
+	if training {
+		// remember the values so the backward pass can compute the gradients
+		// the weights are kept as well because they may be updated before the backward pass
+		n.session.addPending(1)
+		// a forward pass that does not fit in a full cache has nothing to wait for in its backward pass
+		n.session.addPending(-n.cache.Add(append(append([]float64{z, a}, xs...), ws...)...))
 	}
+	x = a
+	//fmt.Printf(">+< %v x = %.3f, z = %.3f\n", n.ID(), x, z)
+
 	// send packet up the chain to all connected neurons
 	for _, conn := range n.Outputs {
-		conn.Forward <- &Packet{
-			NeuronID: &n.id,
-			X:        x,
+		select {
+		case conn.Forward <- &Packet{NeuronID: &n.id, SampleID: sample, Mode: mode, X: x}:
+		case <-done:
+			return n.session.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Backward waits for the gradient of the loss with respect to this neuron's output from every consumer,
+// updates the weights and bias and sends the gradient with respect to each input down to its provider
+// it returns the context's error if the session stops while it is waiting
+func (n *Neuron) Backward() error {
+	done := n.session.ctx.Done()
+	var (
+		da float64
+		dx = make([]float64, len(n.Inputs))
+	)
+
+	// dL/da is the sum of the gradients of every consumer
+	for _, conn := range n.Outputs {
+		if conn.Delayed {
+			continue
+		}
+		select {
+		case packet := <-conn.Backward:
+			da += packet.X
+		case <-done:
+			return n.session.ctx.Err()
+		}
+	}
+	// the gradients of later time steps only come back through delayed connections when going back through time
+	// the mode is checked after the other gradients arrived, so it is the mode they were sent in
+	throughTime := n.session.throughTime()
+	for _, conn := range n.Outputs {
+		if !conn.Delayed || !throughTime {
+			continue
+		}
+		select {
+		case packet := <-conn.Backward:
+			da += packet.X
+		case <-done:
+			return n.session.ctx.Err()
+		}
+	}
+
+	// the cache holds the values of the forward passes in the order they happened,
+	// going back through time they are used from the last time step to the first
+	pop := n.cache.Pop
+	if throughTime {
+		pop = n.cache.PopLast
+	}
+	if cached, ok := pop(); ok {
+		z, xs, ws := cached[0], cached[2:2+len(n.Inputs)], cached[2+len(n.Inputs):]
+		dw := make([]float64, len(n.Inputs))
+		var dp []float64
+		n.mu.Lock()
+		dz := da * n.act.Backward(z)
+		if wp, ok := n.Conf.PreProcessor.(WeightedPreProcessor); ok {
+			dValues, dWeights := wp.BackwardWeighted(xs, ws)
+			for i := range n.Inputs {
+				dw[i] = dz * dWeights[i]
+				dx[i] = dz * dValues[i]
+			}
+		} else {
+			// route the gradient through the reduction to each weighted input
+			dPre := n.Conf.PreProcessor.Backward(weigh(xs, ws))
+			for i := range n.Inputs {
+				dw[i] = dz * dPre[i] * xs[i]
+				dx[i] = dz * dPre[i] * ws[i]
+			}
+		}
+		if la, ok := n.act.(LearnableActivator); ok {
+			dp = la.ParameterGradients(z)
+			for k := range dp {
+				dp[k] *= da
+			}
+		}
+		n.mu.Unlock()
+		// the weights and bias are updated once a batch of gradients has been collected
+		if n.cache.Accumulate(append(append(dw, dz), dp...)...) >= n.session.BatchSize() {
+			n.applyGradients()
 		}
+		n.session.addPending(-1)
 	}
+
+	// send the gradient down the chain to all connected neurons
+	// a gradient is always sent, even without a cached value, so the providers stay in step
+	for i, conn := range n.Inputs {
+		if conn.ProvidingNeuron == nil || conn.Delayed && !throughTime {
+			// nothing is listening on the other side of an input layer's connection
+			continue
+		}
+		select {
+		case conn.Backward <- &Packet{NeuronID: &n.id, X: dx[i]}:
+		case <-done:
+			return n.session.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// applyGradients updates the weights, bias and activator with the mean gradients of the batch
+func (n *Neuron) applyGradients() {
+	grads, count := n.cache.Gradients()
+	if count == 0 || n.Conf.Frozen {
+		return
+	}
+	for i := range grads {
+		grads[i] /= float64(count)
+	}
+	n.UpdateWeightAndBias(grads[:len(n.Inputs)], grads[len(n.Inputs)])
+	n.UpdateActivator(grads[len(n.Inputs)+1:])
 }
 
-func (n *Neuron) UpdateWeightAndBias() {
+// UpdateWeightAndBias takes a gradient descent step using the gradients of the loss
+// with respect to the weight of each input connection (dw) and the bias (db)
+func (n *Neuron) UpdateWeightAndBias(dw []float64, db float64) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	defer n.cache.Zero()
-	// get random factors for weight and bias updates
-	wRandFactor := float64(1)
-	bRandFactor := float64(1)
-	if n.Conf.RandomFactor > 0 {
-		wRandFactor += n.Conf.RandomFactor * rand.NormFloat64()
-		bRandFactor += n.Conf.RandomFactor * rand.NormFloat64()
-	}
-	// get cached values values
-	cached := n.cache.Get()
-	var zSum, z float64
-	for _, c := range cached {
-		zSum += c[1]
-	}
-	// average the a and z values from this neuron's memory
-	z = zSum / float64(len(cached))
-	d := float64(1)
-	if z == 0 {
-		// prevent 0 values
-		z = 1e-10
-	}
-	loss := n.session.Loss()
-	wLoss := loss * (z / (z + n.bias)) // the weight's portion of the loss
-	bLoss := loss - wLoss              // the bias' portion of the loss
-	// update the weight and bias
-	wNew := (d*wLoss*math.Abs(wLoss) * n.session.LearningRate() + n.weight) * wRandFactor
-	bNew := (d*bLoss*math.Abs(bLoss) * n.session.LearningRate() + n.bias) * bRandFactor
-
-	if n.Conf.MaxWeight != 0 {
-		if wNew > n.Conf.MaxWeight {
-			wNew = n.Conf.MaxWeight
-		} else if wNew < -n.Conf.MaxWeight {
-			wNew = -n.Conf.MaxWeight
+	lr := n.session.LearningRate()
+
+	for i, conn := range n.Inputs {
+		if conn.Frozen {
+			continue
+		}
+		if conn.weightState == nil {
+			conn.weightState = n.optimizer().NewState()
+		}
+		wNew := conn.weightState.Step(conn.Weight, dw[i], lr) * n.randFactor()
+		if n.Conf.MaxWeight != 0 {
+			if wNew > n.Conf.MaxWeight {
+				wNew = n.Conf.MaxWeight
+			} else if wNew < -n.Conf.MaxWeight {
+				wNew = -n.Conf.MaxWeight
+			}
+		}
+		if math.Abs(wNew-conn.Weight) > n.Conf.Precision {
+			conn.Weight = wNew
 		}
 	}
+
+	if n.bState == nil {
+		n.bState = n.optimizer().NewState()
+	}
+	bNew := n.bState.Step(n.bias, db, lr) * n.randFactor()
 	if n.Conf.MaxBias != 0 {
 		if bNew > n.Conf.MaxBias {
 			bNew = n.Conf.MaxBias
@@ -113,23 +269,89 @@ func (n *Neuron) UpdateWeightAndBias() {
 			bNew = -n.Conf.MaxBias
 		}
 	}
-	//fmt.Printf("wOld: %.3f bOld: %.3f wRand: %.3f bRand: %.3f\n", n.weight, n.bias, wRandFactor, bRandFactor)
-	//fmt.Printf("loss: %.3f wNew: %.3f bNew: %.3f wLoss: %.3f bLoss: %.3f\n", loss, wNew, bNew, wLoss, bLoss)
-
-	if math.Abs(wNew-n.weight) > n.Conf.Precision {
-		n.weight = wNew
-	}
+	//fmt.Printf("dw: %v db: %.3f bOld: %.3f bNew: %.3f\n", dw, db, n.bias, bNew)
 	if math.Abs(bNew-n.bias) > n.Conf.Precision {
 		n.bias = bNew
 	}
 }
 
+// UpdateActivator takes a gradient descent step for each parameter of a learnable activator
+// using the gradients of the loss with respect to the parameters (dp)
+func (n *Neuron) UpdateActivator(dp []float64) {
+	la, ok := n.act.(LearnableActivator)
+	if !ok || len(dp) == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	lr := n.session.LearningRate()
+	params := la.Parameters()
+	for len(n.aStates) < len(params) {
+		n.aStates = append(n.aStates, n.optimizer().NewState())
+	}
+	for k := range params {
+		params[k] = n.aStates[k].Step(params[k], dp[k], lr)
+	}
+	la.SetParameters(params)
+}
+
+// Activator returns the neuron's activator
+// a learnable activator is copied for every neuron, so each neuron trains its own parameters
+func (n *Neuron) Activator() Activator {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.act
+}
+
+// SetActivator replaces the neuron's activator, used to restore learned activator parameters
+func (n *Neuron) SetActivator(a Activator) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.act = a
+	n.aStates = nil
+}
+
+// weigh multiplies every input by its weight
+func weigh(xs, ws []float64) []float64 {
+	weighted := make([]float64, len(xs))
+	for i, x := range xs {
+		weighted[i] = x * ws[i]
+	}
+	return weighted
+}
+
+// optimizer gets the configured Optimizer
+func (n *Neuron) optimizer() Optimizer {
+	if n.Conf.Optimizer == nil {
+		return &SGD{}
+	}
+	return n.Conf.Optimizer
+}
+
+// randFactor gets a random factor for a weight or bias update
+func (n *Neuron) randFactor() float64 {
+	if n.Conf.RandomFactor > 0 {
+		return 1 + n.Conf.RandomFactor*n.rng.NormFloat64()
+	}
+	return 1
+}
+
 func (n *Neuron) AddInputConnections(conn []*Connection) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	if n.alive {
 		return fmt.Errorf("cannot add connections to active neuron")
 	}
+	for _, c := range conn {
+		if c.ProvidingNeuron == nil {
+			continue
+		}
+		for _, in := range append(n.Inputs, conn...) {
+			if in != c && in.ProvidingNeuron != nil && *in.ProvidingNeuron == *c.ProvidingNeuron && in.Delayed == c.Delayed {
+				return fmt.Errorf("neuron %d is already connected to neuron %d", n.id, *c.ProvidingNeuron)
+			}
+		}
+	}
 	n.Inputs = append(n.Inputs, conn...)
 	return nil
 }
@@ -145,17 +367,27 @@ func (n *Neuron) AddOutputConnections(conn []*Connection) error {
 }
 
 func (n *Neuron) MainLoop() {
+	defer n.session.unregister(n.id)
+	defer func() {
+		n.mu.Lock()
+		n.alive = false
+		n.mu.Unlock()
+	}()
 	for {
 		// keep looping until context is done
-		select {
-		case <-n.session.ctx.Done(): // stop on context cancel
-			n.mu.Lock()
-			n.alive = false
-			n.mu.Unlock()
+		if err := n.Forward(); err != nil {
+			return
+		}
+	}
+}
+
+func (n *Neuron) BackwardLoop() {
+	defer n.session.unregister(n.id)
+	for {
+		// keep looping until context is done
+		if err := n.Backward(); err != nil {
 			return
-		default: // keep running
 		}
-		n.Forward()
 	}
 }
 
@@ -165,35 +397,105 @@ func (n *Neuron) On() {
 	n.mu.Unlock()
 	// start the values and backwards loops in their own routines
 	// this allows backward backwards propagation and values propagation to occur at the same time
+	n.session.register(n.id)
 	go n.MainLoop()
+	if len(n.Outputs) > 0 {
+		n.session.register(n.id)
+		go n.BackwardLoop()
+	}
 }
 
-func NewNeuron(conf *Config, id NeuronID, weight, bias float64, sess *Session) *Neuron {
-	return &Neuron{
+func NewNeuron(conf *Config, id NeuronID, bias float64, sess *Session) *Neuron {
+	act := conf.Activator
+	if la, ok := act.(LearnableActivator); ok {
+		act = la.Clone()
+	}
+	n := &Neuron{
 		Conf:    conf,
 		id:      id,
 		Inputs:  []*Connection{},
 		Outputs: []*Connection{},
 		cache: &NeuronCache{
-			values: [][]float64{},
-			mu:     sync.RWMutex{},
+			size: sess.CacheSize(),
+			mu:   sync.RWMutex{},
 		},
 		bias:    bias,
-		weight:  weight,
 		session: sess,
+		act:     act,
+		rng:     sess.NewRand(id),
 		mu:      sync.Mutex{},
 		alive:   false,
 	}
+	sess.add(n)
+	return n
 }
 
+// ConnectNeurons connects every provider to every consumer
+// the weights are drawn from the Initializer in the config of the first consumer
 func ConnectNeurons(providers []*Neuron, consumers []*Neuron) error {
-	for _, provider := range providers {
-		for _, consumer := range consumers {
+	return ConnectNeuronsWith(providers, consumers, nil)
+}
+
+// ConnectNeuronsWith connects the providers to the consumers chosen by c, every provider to every consumer if c is nil
+func ConnectNeuronsWith(providers []*Neuron, consumers []*Neuron, c Connectivity) error {
+	if len(consumers) == 0 {
+		return nil
+	}
+	return connectNeurons(providers, consumers, consumers[0].Conf.Initializer, c)
+}
+
+func connectNeurons(providers []*Neuron, consumers []*Neuron, init Initializer, c Connectivity) error {
+	if len(providers) == 0 || len(consumers) == 0 {
+		return nil
+	}
+	rng := providers[0].session.Rand()
+	m, err := connectivity(c).Mask(len(providers), len(consumers), rng)
+	if err != nil {
+		return err
+	}
+	if len(m) != len(providers) {
+		return fmt.Errorf("connectivity mask has %d rows, there are %d providers", len(m), len(providers))
+	}
+	var count int
+	outputs, inputs := make([]int, len(providers)), make([]int, len(consumers))
+	for i := range m {
+		if len(m[i]) != len(consumers) {
+			return fmt.Errorf("connectivity mask has %d columns, there are %d consumers", len(m[i]), len(consumers))
+		}
+		for j, connected := range m[i] {
+			if connected {
+				count++
+				outputs[i]++
+				inputs[j]++
+			}
+		}
+	}
+	for i, n := range outputs {
+		if n == 0 {
+			return fmt.Errorf("neuron %d is not connected to any consumer", providers[i].id)
+		}
+	}
+	for j, n := range inputs {
+		if n == 0 {
+			return fmt.Errorf("neuron %d is not connected to any provider", consumers[j].id)
+		}
+	}
+	// the weights are scaled by the number of connections each neuron actually has, on average
+	fanIn := int(math.Round(float64(count) / float64(len(consumers))))
+	fanOut := int(math.Round(float64(count) / float64(len(providers))))
+	weights := initializer(init).Provider(fanIn, fanOut, rng)
+	for i, provider := range providers {
+		for j, consumer := range consumers {
+			if !m[i][j] {
+				continue
+			}
 			conn := NewConnection(provider.ID(), consumer.ID())
-			if err := provider.AddOutputConnections([]*Connection{conn}); err != nil {
+			conn.Weight = weights.RandNew()
+			// the consumer checks for duplicates, so it goes first and a rejected connection is never half made
+			if err := consumer.AddInputConnections([]*Connection{conn}); err != nil {
 				return err
 			}
-			if err := consumer.AddInputConnections([]*Connection{conn}); err != nil {
+			if err := provider.AddOutputConnections([]*Connection{conn}); err != nil {
 				return err
 			}
 		}
@@ -19,14 +19,15 @@ func TestNeuron(t *testing.T) {
 		MaxBias:       2,
 	}
 
-	sess := NewSession(0.003)
+	sess := NewSession(0.0001)
 
 	inputLayer := []*Neuron{
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
 	}
 
 	// add the input connection
 	inputConn := NewConnection(nil, inputLayer[0].ID())
+	inputConn.Weight = rand.Float64()
 	err := inputLayer[0].AddInputConnections([]*Connection{inputConn})
 	if err != nil {
 		panic(err)
@@ -34,10 +35,10 @@ func TestNeuron(t *testing.T) {
 
 	// hiddenLayer layer
 	hiddenLayer := []*Neuron{
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
 	}
 
 	// connect input to hiddenLayer
@@ -48,10 +49,10 @@ func TestNeuron(t *testing.T) {
 
 	// hiddenLayer layer
 	hiddenLayer2 := []*Neuron{
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
 	}
 
 	// connect hidden to hidden
@@ -61,7 +62,7 @@ func TestNeuron(t *testing.T) {
 	}
 
 	outputLayer := []*Neuron{
-		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), rand.Float64(), sess),
+		NewNeuron(conf, sess.NextIDs(1)[0], rand.Float64(), sess),
 	}
 
 	// connect hiddenLayer to output
@@ -87,10 +88,25 @@ func TestNeuron(t *testing.T) {
 	}
 
 	fmt.Printf("all %.1f neurons are running\n", neuronCnt)
+	defer shutdown(t, sess)
 
 	EPOCHS := 10
 	DATASET_SIZE := 100
 
+	// the weights of every neuron, to check that they were trained
+	weights := func() []float64 {
+		var ws []float64
+		for _, l := range [][]*Neuron{inputLayer, hiddenLayer, hiddenLayer2, outputLayer} {
+			for _, n := range l {
+				for _, conn := range n.Inputs {
+					ws = append(ws, conn.Weight)
+				}
+			}
+		}
+		return ws
+	}
+	before := weights()
+
 	// make a dummy dataset
 	dataset := make([][]float64, DATASET_SIZE)
 	for i := 0; i < DATASET_SIZE; i++ {
@@ -106,7 +122,7 @@ func TestNeuron(t *testing.T) {
 		var costSum, pSum, tSum float64
 
 		// loop over the dataset
-		sess.SetMode(MODE_PREDICTING)
+		sess.SetMode(MODE_TRAINING)
 		for i, data := range dataset {
 			x := data[0]
 			y := data[1]
@@ -117,6 +133,11 @@ func TestNeuron(t *testing.T) {
 			out := <-outputConn.Forward // blocking until we have an output
 			yhat := out.X
 			cost := yhat - y
+			// send the gradient of the squared error back through the network
+			outputConn.Backward <- &Packet{
+				NeuronID: nil,
+				X:        cost,
+			}
 			costs[i] = cost
 			predictions[i] = yhat
 			targets[i] = y
@@ -129,14 +150,88 @@ func TestNeuron(t *testing.T) {
 		avgCost := costSum / float64(DATASET_SIZE)
 		avgP := pSum / float64(DATASET_SIZE)
 		avgT := tSum / float64(DATASET_SIZE)
-		// get the mean absolute error
-		sess.SetMode(MODE_TRAINING).
-			 SetLoss((avgP - avgT) / neuronCnt)
-		inputConn.Forward <- &Packet{} // empty packet that will simply trigger each neuron to update
-		// empty the queue
-		<-outputConn.Forward
+		sess.SetLoss(avgCost)
 		fmt.Printf("EPOCH: %d TARGET: %.03f PRED: %.03f ERROR: %.03f\n", i+1, avgT, avgP, avgCost)
 	}
+
+	after := weights()
+	for i := range before {
+		if before[i] == after[i] {
+			t.Errorf("weight %d was not trained, it is still %v", i, before[i])
+		}
+	}
+}
+
+// firstMinusRest is an order sensitive PreProcessor
+type firstMinusRest struct{}
+
+func (p *firstMinusRest) PreProcess(values []float64) float64 {
+	x := values[0]
+	for _, v := range values[1:] {
+		x -= v
+	}
+	return x
+}
+
+func (p *firstMinusRest) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	for i := range grads {
+		grads[i] = -1
+	}
+	grads[0] = 1
+	return grads
+}
+
+func TestNeuronInputOrder(t *testing.T) {
+	conf := &Config{
+		Activator:    &Identity{},
+		PreProcessor: &firstMinusRest{},
+	}
+	sess := NewSession(0.001)
+	neuron := NewNeuron(conf, sess.NextIDs(1)[0], 0, sess)
+
+	// packets without a NeuronID must not overwrite each other
+	inputs := []*Connection{
+		NewConnection(nil, neuron.ID()),
+		NewConnection(nil, neuron.ID()),
+		NewConnection(nil, neuron.ID()),
+	}
+	if err := neuron.AddInputConnections(inputs); err != nil {
+		panic(err)
+	}
+	output := NewConnection(neuron.ID(), nil)
+	if err := neuron.AddOutputConnections([]*Connection{output}); err != nil {
+		panic(err)
+	}
+	neuron.On()
+	defer shutdown(t, sess)
+
+	sess.SetMode(MODE_PREDICTING)
+	for i := 0; i < 20; i++ {
+		for j, conn := range inputs {
+			conn.Forward <- &Packet{X: float64(j + 1)}
+		}
+		if out := <-output.Forward; out.X != 1-2-3 {
+			t.Fatalf("got %.1f want %.1f", out.X, float64(1-2-3))
+		}
+	}
+}
+
+func TestNeuronRejectsDuplicateConnections(t *testing.T) {
+	conf := &Config{
+		Activator:    &Identity{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	ids := sess.NextIDs(2)
+	providers := []*Neuron{NewNeuron(conf, ids[0], 0, sess)}
+	consumers := []*Neuron{NewNeuron(conf, ids[1], 0, sess)}
+	if err := ConnectNeurons(providers, consumers); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConnectNeurons(providers, consumers); err == nil {
+		t.Errorf("expected an error when connecting the same neurons twice")
+	}
 }
 
 //
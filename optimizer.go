@@ -0,0 +1,128 @@
+package neuron
+
+import "math"
+
+// Optimizer decides how a parameter is updated from its gradient
+type Optimizer interface {
+	// NewState creates the state the optimizer keeps for a single parameter
+	NewState() OptimizerState
+}
+
+// OptimizerState is the per-parameter state of an Optimizer
+type OptimizerState interface {
+	// Step returns the new value of a parameter with the value v given its gradient g and the learning rate
+	Step(v, g, lr float64) float64
+}
+
+// SGD is plain stochastic gradient descent
+type SGD struct{}
+
+func (o *SGD) NewState() OptimizerState {
+	return &sgdState{}
+}
+
+type sgdState struct{}
+
+func (s *sgdState) Step(v, g, lr float64) float64 {
+	return v - lr*g
+}
+
+// Momentum is stochastic gradient descent with (optionally Nesterov) momentum
+type Momentum struct {
+	Momentum float64 // fraction of the previous velocity that is kept, 0.9 if zero
+	Nesterov bool
+}
+
+func (o *Momentum) NewState() OptimizerState {
+	return &momentumState{conf: o}
+}
+
+type momentumState struct {
+	conf     *Momentum
+	velocity float64
+}
+
+func (s *momentumState) Step(v, g, lr float64) float64 {
+	mu := orDefault(s.conf.Momentum, 0.9)
+	s.velocity = mu*s.velocity - lr*g
+	if s.conf.Nesterov {
+		// look ahead along the velocity before applying the gradient
+		return v + mu*s.velocity - lr*g
+	}
+	return v + s.velocity
+}
+
+// RMSProp scales the learning rate by a moving average of the squared gradients
+type RMSProp struct {
+	Decay   float64 // 0.9 if zero
+	Epsilon float64 // 1e-8 if zero
+}
+
+func (o *RMSProp) NewState() OptimizerState {
+	return &rmsPropState{conf: o}
+}
+
+type rmsPropState struct {
+	conf *RMSProp
+	sq   float64 // moving average of the squared gradient
+}
+
+func (s *rmsPropState) Step(v, g, lr float64) float64 {
+	decay := orDefault(s.conf.Decay, 0.9)
+	s.sq = decay*s.sq + (1-decay)*g*g
+	return v - lr*g/(math.Sqrt(s.sq)+orDefault(s.conf.Epsilon, 1e-8))
+}
+
+// Adam uses bias corrected moving averages of the gradient and the squared gradient
+type Adam struct {
+	Beta1   float64 // 0.9 if zero
+	Beta2   float64 // 0.999 if zero
+	Epsilon float64 // 1e-8 if zero
+}
+
+func (o *Adam) NewState() OptimizerState {
+	return &adamState{beta1: o.Beta1, beta2: o.Beta2, epsilon: o.Epsilon}
+}
+
+// AdamW is Adam with weight decay that is decoupled from the gradient
+// the decay is applied to every parameter, including biases
+type AdamW struct {
+	Beta1       float64 // 0.9 if zero
+	Beta2       float64 // 0.999 if zero
+	Epsilon     float64 // 1e-8 if zero
+	WeightDecay float64 // 0.01 if zero
+}
+
+func (o *AdamW) NewState() OptimizerState {
+	return &adamState{
+		beta1:       o.Beta1,
+		beta2:       o.Beta2,
+		epsilon:     o.Epsilon,
+		weightDecay: orDefault(o.WeightDecay, 0.01),
+	}
+}
+
+type adamState struct {
+	beta1, beta2, epsilon, weightDecay float64
+	m, v                               float64 // first and second moment
+	t                                  int     // number of steps taken
+}
+
+func (s *adamState) Step(v, g, lr float64) float64 {
+	beta1 := orDefault(s.beta1, 0.9)
+	beta2 := orDefault(s.beta2, 0.999)
+	s.t++
+	s.m = beta1*s.m + (1-beta1)*g
+	s.v = beta2*s.v + (1-beta2)*g*g
+	mHat := s.m / (1 - math.Pow(beta1, float64(s.t)))
+	vHat := s.v / (1 - math.Pow(beta2, float64(s.t)))
+	return v - lr*(mHat/(math.Sqrt(vHat)+orDefault(s.epsilon, 1e-8))+s.weightDecay*v)
+}
+
+// orDefault returns d if v is zero
+func orDefault(v, d float64) float64 {
+	if v == 0 {
+		return d
+	}
+	return v
+}
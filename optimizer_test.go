@@ -0,0 +1,31 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestOptimizers(t *testing.T) {
+	optimizers := map[string]Optimizer{
+		"sgd":      &SGD{},
+		"momentum": &Momentum{Momentum: 0.9},
+		"nesterov": &Momentum{Momentum: 0.9, Nesterov: true},
+		"rmsprop":  &RMSProp{},
+		"adam":     &Adam{},
+		"adamw":    &AdamW{WeightDecay: 0.0001},
+	}
+
+	for name, opt := range optimizers {
+		// minimize (v - 3)^2
+		state := opt.NewState()
+		v := float64(-2)
+		for i := 0; i < 2000; i++ {
+			v = state.Step(v, 2*(v-3), 0.01)
+		}
+		fmt.Printf("%s: %.05f\n", name, v)
+		if math.Abs(v-3) > 0.01 {
+			t.Errorf("%s did not converge: got %.05f want 3", name, v)
+		}
+	}
+}
@@ -1,20 +1,255 @@
 package neuron
 
+import (
+	"math"
+	"sort"
+)
 
+// PreProcessor reduces the weighted inputs of a neuron to a single value
 type PreProcessor interface {
 	PreProcess(values []float64) float64
+	// Backward returns the derivative of PreProcess(values) with respect to each value
+	Backward(values []float64) []float64
 }
 
-type SumPreProcessor struct {}
+// WeightedPreProcessor is a PreProcessor that applies the weights of the input connections itself
+// a neuron passes it the raw inputs and the weights instead of the weighted inputs
+type WeightedPreProcessor interface {
+	PreProcessor
+	PreProcessWeighted(values, weights []float64) float64
+	// BackwardWeighted returns the derivatives of PreProcessWeighted with respect to each value and each weight
+	BackwardWeighted(values, weights []float64) (dValues, dWeights []float64)
+}
+
+type SumPreProcessor struct{}
 
 func (p *SumPreProcessor) PreProcess(values []float64) float64 {
-	var (
-		sum float64
-		cnt int
-	)
+	var sum float64
 	for _, v := range values {
 		sum += v
-		cnt++
 	}
 	return sum
 }
+
+func (p *SumPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	for i := range grads {
+		grads[i] = 1
+	}
+	return grads
+}
+
+func (p *SumPreProcessor) Spec() string {
+	return "sum"
+}
+
+type MeanPreProcessor struct{}
+
+func (p *MeanPreProcessor) PreProcess(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func (p *MeanPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	for i := range grads {
+		grads[i] = 1 / float64(len(values))
+	}
+	return grads
+}
+
+func (p *MeanPreProcessor) Spec() string {
+	return "mean"
+}
+
+// MaxPreProcessor passes the gradient only to the largest value
+type MaxPreProcessor struct{}
+
+func (p *MaxPreProcessor) PreProcess(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[ArgMax(values)]
+}
+
+func (p *MaxPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	if len(values) > 0 {
+		grads[ArgMax(values)] = 1
+	}
+	return grads
+}
+
+func (p *MaxPreProcessor) Spec() string {
+	return "max"
+}
+
+// MinPreProcessor passes the gradient only to the smallest value
+type MinPreProcessor struct{}
+
+func (p *MinPreProcessor) PreProcess(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[argMin(values)]
+}
+
+func (p *MinPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	if len(values) > 0 {
+		grads[argMin(values)] = 1
+	}
+	return grads
+}
+
+func (p *MinPreProcessor) Spec() string {
+	return "min"
+}
+
+type ProductPreProcessor struct{}
+
+func (p *ProductPreProcessor) PreProcess(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	product := float64(1)
+	for _, v := range values {
+		product *= v
+	}
+	return product
+}
+
+func (p *ProductPreProcessor) Backward(values []float64) []float64 {
+	// the product of every other value, built from prefix and suffix products so zeros are handled
+	grads := make([]float64, len(values))
+	prefix := float64(1)
+	for i, v := range values {
+		grads[i] = prefix
+		prefix *= v
+	}
+	suffix := float64(1)
+	for i := len(values) - 1; i >= 0; i-- {
+		grads[i] *= suffix
+		suffix *= values[i]
+	}
+	return grads
+}
+
+func (p *ProductPreProcessor) Spec() string {
+	return "product"
+}
+
+// NormPreProcessor is the euclidean (L2) norm of the values
+type NormPreProcessor struct{}
+
+func (p *NormPreProcessor) PreProcess(values []float64) float64 {
+	var sq float64
+	for _, v := range values {
+		sq += v * v
+	}
+	return math.Sqrt(sq)
+}
+
+func (p *NormPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	norm := p.PreProcess(values)
+	if norm == 0 {
+		return grads
+	}
+	for i, v := range values {
+		grads[i] = v / norm
+	}
+	return grads
+}
+
+func (p *NormPreProcessor) Spec() string {
+	return "l2_norm"
+}
+
+// MedianPreProcessor passes the gradient to the middle value, or splits it between the two middle values
+type MedianPreProcessor struct{}
+
+func (p *MedianPreProcessor) PreProcess(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	lo, hi := middle(values)
+	return (values[lo] + values[hi]) / 2
+}
+
+func (p *MedianPreProcessor) Backward(values []float64) []float64 {
+	grads := make([]float64, len(values))
+	if len(values) == 0 {
+		return grads
+	}
+	lo, hi := middle(values)
+	grads[lo] += 0.5
+	grads[hi] += 0.5
+	return grads
+}
+
+func (p *MedianPreProcessor) Spec() string {
+	return "median"
+}
+
+// WeightedMeanPreProcessor is the mean of the values weighted by the softmax of the connection weights
+// so the weights always sum to one
+type WeightedMeanPreProcessor struct{}
+
+func (p *WeightedMeanPreProcessor) PreProcess(values []float64) float64 {
+	return (&MeanPreProcessor{}).PreProcess(values)
+}
+
+func (p *WeightedMeanPreProcessor) Backward(values []float64) []float64 {
+	return (&MeanPreProcessor{}).Backward(values)
+}
+
+func (p *WeightedMeanPreProcessor) PreProcessWeighted(values, weights []float64) float64 {
+	var mean float64
+	for i, s := range Softmax(weights) {
+		mean += s * values[i]
+	}
+	return mean
+}
+
+func (p *WeightedMeanPreProcessor) BackwardWeighted(values, weights []float64) ([]float64, []float64) {
+	dValues := Softmax(weights)
+	dWeights := make([]float64, len(values))
+	mean := p.PreProcessWeighted(values, weights)
+	for i, s := range dValues {
+		dWeights[i] = s * (values[i] - mean)
+	}
+	return dValues, dWeights
+}
+
+func (p *WeightedMeanPreProcessor) Spec() string {
+	return "weighted_mean"
+}
+
+// argMin returns the index of the smallest value
+func argMin(xs []float64) int {
+	best := -1
+	for i, x := range xs {
+		if best < 0 || x < xs[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// middle returns the indices of the lower and upper middle values, which are the same for an odd count
+func middle(values []float64) (int, int) {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+	n := len(idx)
+	return idx[(n-1)/2], idx[n/2]
+}
@@ -0,0 +1,117 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPreProcessorGradients(t *testing.T) {
+	const h = 1e-6
+	preProcessors := []PreProcessor{
+		&SumPreProcessor{},
+		&MeanPreProcessor{},
+		&MaxPreProcessor{},
+		&MinPreProcessor{},
+		&ProductPreProcessor{},
+		&NormPreProcessor{},
+		&MedianPreProcessor{},
+		&WeightedMeanPreProcessor{},
+	}
+	// no ties, so max, min and median are differentiable
+	inputs := [][]float64{
+		{0.5, -1.2, 2.3},
+		{1.5, 0, -0.7, 0.2},
+		{3},
+	}
+
+	for _, pre := range preProcessors {
+		spec, _ := SpecOf(pre)
+		if _, err := NewPreProcessor(spec); err != nil {
+			t.Errorf("%s: %v", spec, err)
+		}
+		for _, values := range inputs {
+			grads := pre.Backward(values)
+			for i := range values {
+				up := append([]float64{}, values...)
+				down := append([]float64{}, values...)
+				up[i] += h
+				down[i] -= h
+				numerical := (pre.PreProcess(up) - pre.PreProcess(down)) / (2 * h)
+				if math.Abs(grads[i]-numerical) > 1e-5 {
+					t.Errorf("%s: gradient %d of %v is %.06f, numerically %.06f", spec, i, values, grads[i], numerical)
+				}
+			}
+		}
+	}
+
+	wp := &WeightedMeanPreProcessor{}
+	values, weights := []float64{0.5, -1.2, 2.3}, []float64{0.1, 1.5, -0.4}
+	dValues, dWeights := wp.BackwardWeighted(values, weights)
+	for i := range values {
+		up := append([]float64{}, values...)
+		down := append([]float64{}, values...)
+		up[i] += h
+		down[i] -= h
+		numerical := (wp.PreProcessWeighted(up, weights) - wp.PreProcessWeighted(down, weights)) / (2 * h)
+		if math.Abs(dValues[i]-numerical) > 1e-5 {
+			t.Errorf("weighted_mean: value gradient %d is %.06f, numerically %.06f", i, dValues[i], numerical)
+		}
+		up = append([]float64{}, weights...)
+		down = append([]float64{}, weights...)
+		up[i] += h
+		down[i] -= h
+		numerical = (wp.PreProcessWeighted(values, up) - wp.PreProcessWeighted(values, down)) / (2 * h)
+		if math.Abs(dWeights[i]-numerical) > 1e-5 {
+			t.Errorf("weighted_mean: weight gradient %d is %.06f, numerically %.06f", i, dWeights[i], numerical)
+		}
+	}
+}
+
+func TestMaxPreProcessorRoutesToArgMax(t *testing.T) {
+	identity := &Config{
+		Activator:    &Identity{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.1)
+	input, err := NewInputLayer("input", identity, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", &Config{
+		Activator:    &Identity{},
+		PreProcessor: &MaxPreProcessor{},
+	}, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(input.Layer, output.Layer); err != nil {
+		panic(err)
+	}
+	// make the input layer pass its inputs through unchanged and give the output neuron equal weights
+	for _, n := range input.Layer.Neurons {
+		n.bias = 0
+	}
+	for _, conn := range input.Inputs {
+		conn.Weight = 1
+	}
+	neuron := output.Layer.Neurons[0]
+	for _, conn := range neuron.Inputs {
+		conn.Weight = 1
+	}
+	input.Layer.On()
+	output.Layer.On()
+	defer shutdown(t, sess)
+
+	if _, err := NewTrainer(sess, input, output).Fit([]Sample{{X: []float64{0.2, 0.9, 0.4}, Y: []float64{0}}}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	neuron.mu.Lock()
+	defer neuron.mu.Unlock()
+	for i, conn := range neuron.Inputs {
+		changed := conn.Weight != 1
+		if changed != (i == 1) {
+			t.Errorf("weight %d changed: %v, only the weight of the largest input should change", i, changed)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package neuron
 
 import (
 	"math/rand"
+	"sync"
 )
 
 type RandProvider interface {
@@ -9,9 +10,66 @@ type RandProvider interface {
 }
 
 type RandNormal struct {
-	Scale float64
+	Mean   float64
+	Scale  float64
+	Source *rand.Rand // the global source is used if nil
 }
 
 func (r *RandNormal) RandNew() float64 {
-	return rand.NormFloat64() * r.Scale
+	if r.Source != nil {
+		return r.Mean + r.Source.NormFloat64()*r.Scale
+	}
+	return r.Mean + rand.NormFloat64()*r.Scale
+}
+
+// RandUniform draws values uniformly from [Min, Max)
+type RandUniform struct {
+	Min    float64
+	Max    float64
+	Source *rand.Rand // the global source is used if nil
+}
+
+func (r *RandUniform) RandNew() float64 {
+	if r.Source != nil {
+		return r.Min + r.Source.Float64()*(r.Max-r.Min)
+	}
+	return r.Min + rand.Float64()*(r.Max-r.Min)
+}
+
+// RandConstant always returns Value
+type RandConstant struct {
+	Value float64
+}
+
+func (r *RandConstant) RandNew() float64 {
+	return r.Value
+}
+
+// lockedSource makes a rand.Source safe to share between goroutines
+type lockedSource struct {
+	src rand.Source64
+	mu  sync.Mutex
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// newLockedRand creates a goroutine safe rand.Rand
+func newLockedRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
 }
@@ -0,0 +1,321 @@
+package neuron
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// elman builds input -> hidden -> output where the hidden layer also gets its own outputs of the previous time step
+func elman(sess *Session, hiddenNeurons int) *Network {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+		Initializer:  &Xavier{},
+	}
+	input, err := NewInputLayer("input", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, hiddenNeurons)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	if err := ConnectRecurrent(hidden, hidden, 0.1); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", &Config{Activator: &Identity{}, PreProcessor: &SumPreProcessor{}}, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	return &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+}
+
+func TestRecurrentState(t *testing.T) {
+	sess := NewSession(0.01).SetSeed(5)
+	net := elman(sess, 3)
+	net.On()
+	defer shutdown(t, sess)
+
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	xs := [][]float64{{1}, {0}, {0}, {-1}}
+	first, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the state carries on, so the same inputs give different outputs
+	second, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first, second) {
+		t.Errorf("expected the state of the first sequence to change the second")
+	}
+	if err := sess.ResetState(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("got %v after resetting the state, want %v", again, first)
+	}
+}
+
+func TestRecurrentConnectedFirst(t *testing.T) {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.01).SetSeed(6)
+	input, err := NewInputLayer("input", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 2)
+	// the delayed inputs come before the regular ones
+	if err := ConnectRecurrent(hidden, hidden, 0); err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+	trainer := NewTrainer(sess, net.Input, net.Output)
+
+	xs := [][]float64{{1}, {-1}, {0.5}}
+	first, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the delayed connections still hold the last time step, so the state can be reset
+	if err := sess.ResetState(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("got %v after resetting the state, want %v", again, first)
+	}
+
+	// the outputs belong to the sample that was sent, not the previous time step
+	for _, id := range sess.NextSampleIDs(3) {
+		outputs, err := trainer.forward(context.Background(), xs[0], id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outputs[0].SampleID != id {
+			t.Errorf("got the outputs of sample %d, want %d", outputs[0].SampleID, id)
+		}
+	}
+}
+
+func TestBackpropThroughTime(t *testing.T) {
+	sess := NewSession(0.1).SetSeed(9).SetBatchSize(1000)
+	net := elman(sess, 2)
+	net.On()
+	defer shutdown(t, sess)
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	seq := []Sample{{X: []float64{1}, Y: []float64{0}}, {X: []float64{0}, Y: []float64{1}}, {X: []float64{-1}, Y: []float64{0}}, {X: []float64{0.5}, Y: []float64{-1}}}
+
+	// the loss of the whole sequence from a fresh state
+	loss := func() float64 {
+		if err := sess.ResetState(); err != nil {
+			t.Fatal(err)
+		}
+		xs := make([][]float64, len(seq))
+		for i, s := range seq {
+			xs[i] = s.X
+		}
+		ys, err := trainer.RunSequence(xs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sum float64
+		for i, y := range ys {
+			l, _, err := net.Output.Cost.Cost(y, seq[i].Y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum += l
+		}
+		return sum
+	}
+
+	// the recurrent weights and the weight from the input
+	hidden := net.Layers[0].Neurons[0]
+	conns := hidden.Inputs
+	numerical := make([]float64, len(conns))
+	before := make([]float64, len(conns))
+	const eps = 1e-6
+	for i, conn := range conns {
+		before[i] = conn.Weight
+		conn.Weight = before[i] + eps
+		up := loss()
+		conn.Weight = before[i] - eps
+		down := loss()
+		conn.Weight = before[i]
+		numerical[i] = (up - down) / (2 * eps)
+	}
+
+	// the mean gradient of the time steps is applied once at the end of the epoch
+	if err := sess.ResetState(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trainer.FitSequences([][]Sample{seq}, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i, conn := range conns {
+		got := -(conn.Weight - before[i]) * float64(len(seq)) / 0.1
+		fmt.Printf("delayed %v: bptt %.08f numerical %.08f\n", conn.Delayed, got, numerical[i])
+		if math.Abs(got-numerical[i]) > 1e-5*math.Max(1, math.Abs(numerical[i])) {
+			t.Errorf("connection %d: got gradient %.08f want %.08f", i, got, numerical[i])
+		}
+	}
+}
+
+func TestTruncatedBackpropThroughTime(t *testing.T) {
+	sess := NewSession(0.05).SetSeed(2)
+	net := elman(sess, 4)
+	net.On()
+	defer shutdown(t, sess)
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	trainer.Truncate = 3
+
+	// the target is the input of the previous time step, so it has to be remembered
+	var sequences [][]Sample
+	for s := 0; s < 4; s++ {
+		seq := make([]Sample, 10)
+		prev := 0.0
+		for i := range seq {
+			x := math.Sin(float64(s*10+i) * 1.7)
+			seq[i] = Sample{X: []float64{x}, Y: []float64{prev}}
+			prev = x
+		}
+		sequences = append(sequences, seq)
+	}
+	first, err := trainer.FitSequences(sequences, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := trainer.FitSequences(sequences, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("truncated bptt loss: first %.05f last %.05f\n", first, last)
+	if last >= first {
+		t.Errorf("expected the loss to improve, got %.05f after %.05f", last, first)
+	}
+}
+
+func TestStopDuringSequenceTraining(t *testing.T) {
+	sess := NewSession(0.01).SetSeed(4)
+	net := elman(sess, 3)
+	net.On()
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	seq := make([]Sample, 20)
+	for i := range seq {
+		seq[i] = Sample{X: []float64{math.Sin(float64(i))}, Y: []float64{math.Cos(float64(i))}}
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := trainer.FitSequences([][]Sample{seq}, 100000)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	sess.Stop()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected training to stop with the session")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("training did not stop with the session")
+	}
+	shutdown(t, sess)
+}
+
+func TestJordanGraph(t *testing.T) {
+	conf := &Config{
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.01)
+	// the output of the previous time step goes back into the hidden layer
+	net, err := NewGraph(sess).
+		Input("input", conf, 2).
+		Layer("hidden", conf, 3).
+		Output("output", conf, 2).
+		Connect("input", "hidden").
+		Connect("hidden", "output").
+		Recurrent("output", "hidden", 0.5).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.On()
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	xs := [][]float64{{1, 0}, {0, 1}, {0, 0}}
+	if _, err := trainer.FitSequences([][]Sample{{{X: xs[0], Y: []float64{0, 1}}, {X: xs[1], Y: []float64{1, 0}}}}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.ResetState(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := trainer.RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Save(&buf, FORMAT_GOB, net); err != nil {
+		t.Fatal(err)
+	}
+	shutdown(t, sess)
+	loaded, err := Load(&buf, FORMAT_GOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.On()
+	defer shutdown(t, loaded.Sess)
+	got, err := NewTrainer(loaded.Sess, loaded.Input, loaded.Output).RunSequence(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded network got %v want %v", got, want)
+	}
+
+	// without the delay the same graph is a cycle
+	_, err = NewGraph(NewSession(0.01)).
+		Input("input", conf, 2).
+		Layer("hidden", conf, 3).
+		Output("output", conf, 2).
+		Connect("input", "hidden").
+		Connect("hidden", "output").
+		Connect("hidden", "hidden").
+		Build()
+	if err == nil {
+		t.Errorf("expected an undelayed cycle to be refused")
+	}
+}
@@ -0,0 +1,271 @@
+package neuron
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Params are the named parameters of a spec like "leaky_relu(alpha=0.5)"
+type Params map[string]float64
+
+// Get returns the parameter or d if it is not set
+func (p Params) Get(name string, d float64) float64 {
+	if v, ok := p[name]; ok {
+		return v
+	}
+	return d
+}
+
+// Only returns an error if any parameter other than names is set
+func (p Params) Only(names ...string) error {
+	for k := range p {
+		known := false
+		for _, name := range names {
+			if k == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	return nil
+}
+
+// Specer is implemented by components that can describe themselves as a spec the registry can create them from
+type Specer interface {
+	Spec() string
+}
+
+type ActivatorConstructor func(p Params) (Activator, error)
+
+type PreProcessorConstructor func(p Params) (PreProcessor, error)
+
+var registry = struct {
+	activators    map[string]ActivatorConstructor
+	preProcessors map[string]PreProcessorConstructor
+	mu            sync.RWMutex
+}{
+	activators: map[string]ActivatorConstructor{
+		"relu": func(p Params) (Activator, error) {
+			return &Relu{}, p.Only()
+		},
+		"leaky_relu": func(p Params) (Activator, error) {
+			return &LeakyRelu{Alpha: p.Get("alpha", 0.01)}, p.Only("alpha")
+		},
+		"sigmoid": func(p Params) (Activator, error) {
+			return &Sigmoid{}, p.Only()
+		},
+		"tanh": func(p Params) (Activator, error) {
+			return &Tanh{}, p.Only()
+		},
+		"elu": func(p Params) (Activator, error) {
+			return &ELU{Alpha: p.Get("alpha", 1)}, p.Only("alpha")
+		},
+		"selu": func(p Params) (Activator, error) {
+			return &SELU{}, p.Only()
+		},
+		"gelu": func(p Params) (Activator, error) {
+			return &GELU{}, p.Only()
+		},
+		"swish": func(p Params) (Activator, error) {
+			return &Swish{Beta: p.Get("beta", 1)}, p.Only("beta")
+		},
+		"silu": func(p Params) (Activator, error) {
+			return &Swish{Beta: 1}, p.Only()
+		},
+		"softplus": func(p Params) (Activator, error) {
+			return &Softplus{}, p.Only()
+		},
+		"hard_sigmoid": func(p Params) (Activator, error) {
+			return &HardSigmoid{}, p.Only()
+		},
+		"identity": func(p Params) (Activator, error) {
+			return &Identity{}, p.Only()
+		},
+		"mish": func(p Params) (Activator, error) {
+			return &Mish{}, p.Only()
+		},
+		"prelu": func(p Params) (Activator, error) {
+			return &PReLU{Alpha: p.Get("alpha", 0.25)}, p.Only("alpha")
+		},
+		"pswish": func(p Params) (Activator, error) {
+			return &PSwish{Beta: p.Get("beta", 1)}, p.Only("beta")
+		},
+	},
+	preProcessors: map[string]PreProcessorConstructor{
+		"sum": func(p Params) (PreProcessor, error) {
+			return &SumPreProcessor{}, p.Only()
+		},
+		"mean": func(p Params) (PreProcessor, error) {
+			return &MeanPreProcessor{}, p.Only()
+		},
+		"max": func(p Params) (PreProcessor, error) {
+			return &MaxPreProcessor{}, p.Only()
+		},
+		"min": func(p Params) (PreProcessor, error) {
+			return &MinPreProcessor{}, p.Only()
+		},
+		"product": func(p Params) (PreProcessor, error) {
+			return &ProductPreProcessor{}, p.Only()
+		},
+		"l2_norm": func(p Params) (PreProcessor, error) {
+			return &NormPreProcessor{}, p.Only()
+		},
+		"median": func(p Params) (PreProcessor, error) {
+			return &MedianPreProcessor{}, p.Only()
+		},
+		"weighted_mean": func(p Params) (PreProcessor, error) {
+			return &WeightedMeanPreProcessor{}, p.Only()
+		},
+	},
+}
+
+// RegisterActivator makes NewActivator create activators named name with c
+func RegisterActivator(name string, c ActivatorConstructor) error {
+	if !validName(name) {
+		return fmt.Errorf("invalid activator name %q", name)
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.activators[name]; ok {
+		return fmt.Errorf("activator %q is already registered", name)
+	}
+	registry.activators[name] = c
+	return nil
+}
+
+// RegisterPreProcessor makes NewPreProcessor create pre-processors named name with c
+func RegisterPreProcessor(name string, c PreProcessorConstructor) error {
+	if !validName(name) {
+		return fmt.Errorf("invalid pre-processor name %q", name)
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.preProcessors[name]; ok {
+		return fmt.Errorf("pre-processor %q is already registered", name)
+	}
+	registry.preProcessors[name] = c
+	return nil
+}
+
+// NewActivator creates an activator from a spec like "relu" or "leaky_relu(alpha=0.5)"
+func NewActivator(spec string) (Activator, error) {
+	name, p, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	registry.mu.RLock()
+	c, ok := registry.activators[name]
+	registry.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown activator %q", name)
+	}
+	a, err := c(p)
+	if err != nil {
+		return nil, fmt.Errorf("activator %s: %v", name, err)
+	}
+	return a, nil
+}
+
+// NewPreProcessor creates a pre-processor from a spec like "sum"
+func NewPreProcessor(spec string) (PreProcessor, error) {
+	name, p, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	registry.mu.RLock()
+	c, ok := registry.preProcessors[name]
+	registry.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown pre-processor %q", name)
+	}
+	pre, err := c(p)
+	if err != nil {
+		return nil, fmt.Errorf("pre-processor %s: %v", name, err)
+	}
+	return pre, nil
+}
+
+// SpecOf returns the spec of an activator or pre-processor
+func SpecOf(c interface{}) (string, error) {
+	s, ok := c.(Specer)
+	if !ok {
+		return "", fmt.Errorf("%T does not implement Specer", c)
+	}
+	return s.Spec(), nil
+}
+
+// FormatSpec builds a spec from a name and its parameters, the parameters are sorted by name
+func FormatSpec(name string, p Params) string {
+	if len(p) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.FormatFloat(p[k], 'g', -1, 64)
+	}
+	return name + "(" + strings.Join(parts, ",") + ")"
+}
+
+// ParseSpec splits a spec like "leaky_relu(alpha=0.5)" into its name and parameters
+func ParseSpec(spec string) (string, Params, error) {
+	spec = strings.TrimSpace(spec)
+	p := Params{}
+	open := strings.IndexByte(spec, '(')
+	if open < 0 {
+		if !validName(spec) {
+			return "", nil, fmt.Errorf("invalid spec %q", spec)
+		}
+		return spec, p, nil
+	}
+	name := strings.TrimSpace(spec[:open])
+	if !validName(name) || !strings.HasSuffix(spec, ")") {
+		return "", nil, fmt.Errorf("invalid spec %q", spec)
+	}
+	body := strings.TrimSpace(spec[open+1 : len(spec)-1])
+	if body == "" {
+		return name, p, nil
+	}
+	for _, part := range strings.Split(body, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("invalid parameter %q in spec %q", part, spec)
+		}
+		k := strings.TrimSpace(kv[0])
+		if !validName(k) {
+			return "", nil, fmt.Errorf("invalid parameter name %q in spec %q", k, spec)
+		}
+		if _, ok := p[k]; ok {
+			return "", nil, fmt.Errorf("duplicate parameter %q in spec %q", k, spec)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value for parameter %q in spec %q", k, spec)
+		}
+		p[k] = v
+	}
+	return name, p, nil
+}
+
+// validName allows lower case letters, digits and underscores
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+type doubler struct {
+	Factor float64
+}
+
+func (d *doubler) Forward(input float64) float64 {
+	return d.Factor * input
+}
+
+func (d *doubler) Backward(input float64) float64 {
+	return d.Factor
+}
+
+func (d *doubler) Spec() string {
+	return FormatSpec("test_doubler", Params{"factor": d.Factor})
+}
+
+func TestRegistry(t *testing.T) {
+	// every built-in creates itself from its own spec
+	for _, a := range []Activator{&Relu{}, &LeakyRelu{0.5}, &LeakyRelu{}} {
+		spec, err := SpecOf(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewActivator(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := SpecOf(b); got != spec {
+			t.Errorf("%s round tripped as %s", spec, got)
+		}
+	}
+	for _, p := range []PreProcessor{&SumPreProcessor{}} {
+		spec, err := SpecOf(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := NewPreProcessor(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := SpecOf(q); got != spec {
+			t.Errorf("%s round tripped as %s", spec, got)
+		}
+	}
+
+	a, err := NewActivator(" leaky_relu( alpha = 0.25 ) ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alpha := a.(*LeakyRelu).Alpha; alpha != 0.25 {
+		t.Errorf("got alpha %.2f want 0.25", alpha)
+	}
+
+	for _, spec := range []string{"", "relu(", "Relu", "unknown", "relu(alpha=1)", "leaky_relu(alpha)", "leaky_relu(alpha=x)", "leaky_relu(alpha=1,alpha=2)"} {
+		if _, err := NewActivator(spec); err == nil {
+			t.Errorf("expected an error for spec %q", spec)
+		}
+	}
+
+	if err := RegisterActivator("test_doubler", func(p Params) (Activator, error) {
+		return &doubler{Factor: p.Get("factor", 2)}, p.Only("factor")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterActivator("test_doubler", nil); err == nil {
+		t.Errorf("expected an error when registering a name twice")
+	}
+	if err := RegisterPreProcessor("Bad Name", nil); err == nil {
+		t.Errorf("expected an error when registering an invalid name")
+	}
+	d, err := NewActivator("test_doubler(factor=3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Forward(2); math.Abs(got-6) > 1e-12 {
+		t.Errorf("got %.2f want 6", got)
+	}
+	if spec, _ := SpecOf(d); spec != "test_doubler(factor=3)" {
+		t.Errorf("unexpected spec %s", spec)
+	}
+}
@@ -0,0 +1,191 @@
+package neuron
+
+import "fmt"
+
+// relayConfig is the config of the neurons that delay a layer's outputs, they pass on their input unchanged
+var relayConfig = fixedConfig(&Identity{}, &SumPreProcessor{})
+
+// fixedConfig is the config of neurons that are never trained, every input has a weight of 1 and the bias is 0
+func fixedConfig(act Activator, pre PreProcessor) *Config {
+	return &Config{
+		Activator:       act,
+		PreProcessor:    pre,
+		Initializer:     &Constant{Value: 1},
+		BiasInitializer: &Zeros{},
+		Frozen:          true,
+	}
+}
+
+// depths returns the number of neurons on the longest path from an input layer to each neuron of the session
+// the neurons of an input layer, and neurons that are not connected yet, have a depth of 0
+func (s *Session) depths() map[NeuronID]int {
+	s.mu.RLock()
+	neurons := map[NeuronID]*Neuron{}
+	for _, n := range s.neurons {
+		neurons[n.id] = n
+	}
+	s.mu.RUnlock()
+
+	depths := map[NeuronID]int{}
+	var depth func(n *Neuron) int
+	depth = func(n *Neuron) int {
+		if d, ok := depths[n.id]; ok {
+			return d
+		}
+		// a cycle is cut here rather than followed forever
+		depths[n.id] = 0
+		d := 0
+		for _, conn := range n.Inputs {
+			if conn.ProvidingNeuron == nil || conn.Delayed {
+				continue
+			}
+			if p, ok := neurons[*conn.ProvidingNeuron]; ok && depth(p)+1 > d {
+				d = depth(p) + 1
+			}
+		}
+		depths[n.id] = d
+		return d
+	}
+	for _, n := range neurons {
+		depth(n)
+	}
+	return depths
+}
+
+// Depth returns the number of neurons on the longest path from the input layer to the layer
+func (l *Layer) Depth() int {
+	return layerDepth(l, l.Sess.depths())
+}
+
+// Concat connects every neuron of every provider to every neuron of the layer, like ConnectLayers,
+// but makes sure every path into the layer has the same length
+// a provider that is closer to the input layer than the others is delayed by relay layers of frozen identity neurons,
+// the relay layers are returned and must be turned on with the rest of the network
+func (l *Layer) Concat(providers ...*Layer) ([]*Layer, error) {
+	for _, p := range providers {
+		if p.Sess != l.Sess {
+			return nil, fmt.Errorf("groups must be part of the same session")
+		}
+	}
+	depths := l.Sess.depths()
+	target, err := l.inputDepth(depths)
+	if err != nil {
+		return nil, err
+	}
+	existing := target
+	for _, p := range providers {
+		if d := layerDepth(p, depths); d > target {
+			target = d
+		}
+	}
+	if existing >= 0 && existing < target {
+		return nil, fmt.Errorf("layer %s already has inputs at depth %d, it can not take inputs at depth %d", l.Name, existing, target)
+	}
+
+	var relays []*Layer
+	for _, p := range providers {
+		delayed, err := l.delay(p, target-layerDepth(p, depths))
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, delayed...)
+		if len(delayed) > 0 {
+			p = delayed[len(delayed)-1]
+		}
+		if err := ConnectLayers(p, l); err != nil {
+			return nil, err
+		}
+	}
+	return relays, nil
+}
+
+// Residual adds the output of each neuron of x to the input of the neuron of the layer at the same position,
+// through a frozen connection with a weight of 1, so with an Identity activator the layer computes x + f(x)
+// x is delayed like in Concat if it is closer to the input layer than the layer's other inputs
+func (l *Layer) Residual(x *Layer) ([]*Layer, error) {
+	if x.Sess != l.Sess {
+		return nil, fmt.Errorf("groups must be part of the same session")
+	}
+	if len(x.Neurons) != len(l.Neurons) {
+		return nil, fmt.Errorf("layer %s has %d neurons, residual %s has %d", l.Name, len(l.Neurons), x.Name, len(x.Neurons))
+	}
+	depths := l.Sess.depths()
+	target, err := l.inputDepth(depths)
+	if err != nil {
+		return nil, err
+	}
+	if target < 0 {
+		return nil, fmt.Errorf("layer %s must be connected to the layers it adds x to first", l.Name)
+	}
+	d := layerDepth(x, depths)
+	if d > target {
+		return nil, fmt.Errorf("layer %s has inputs at depth %d, residual %s is at depth %d", l.Name, target, x.Name, d)
+	}
+	relays, err := l.delay(x, target-d)
+	if err != nil {
+		return nil, err
+	}
+	if len(relays) > 0 {
+		x = relays[len(relays)-1]
+	}
+	if err := connectOneToOne(x.Neurons, l.Neurons, true); err != nil {
+		return nil, err
+	}
+	return relays, nil
+}
+
+// inputDepth returns the depth of the providers the layer is connected to, -1 if it has none
+func (l *Layer) inputDepth(depths map[NeuronID]int) (int, error) {
+	d := -1
+	for _, n := range l.Neurons {
+		for _, conn := range n.Inputs {
+			if conn.ProvidingNeuron == nil || conn.Delayed {
+				continue
+			}
+			pd := depths[*conn.ProvidingNeuron]
+			if d >= 0 && pd != d {
+				return 0, fmt.Errorf("layer %s has inputs at depths %d and %d", l.Name, d, pd)
+			}
+			d = pd
+		}
+	}
+	return d, nil
+}
+
+// delay chains levels relay layers after p
+func (l *Layer) delay(p *Layer, levels int) ([]*Layer, error) {
+	relays := make([]*Layer, levels)
+	for i := range relays {
+		relays[i] = NewLayer(fmt.Sprintf("%s_to_%s_relay%d", p.Name, l.Name, i+1), relayConfig, l.Sess, len(p.Neurons))
+		if err := connectOneToOne(p.Neurons, relays[i].Neurons, true); err != nil {
+			return nil, err
+		}
+		p = relays[i]
+	}
+	return relays, nil
+}
+
+func layerDepth(l *Layer, depths map[NeuronID]int) int {
+	d := 0
+	for _, n := range l.Neurons {
+		if depths[n.id] > d {
+			d = depths[n.id]
+		}
+	}
+	return d
+}
+
+// connectOneToOne connects each provider to the consumer at the same position with a weight of 1
+func connectOneToOne(providers, consumers []*Neuron, frozen bool) error {
+	for i, provider := range providers {
+		conn := NewConnection(provider.ID(), consumers[i].ID())
+		conn.Frozen = frozen
+		if err := consumers[i].AddInputConnections([]*Connection{conn}); err != nil {
+			return err
+		}
+		if err := provider.AddOutputConnections([]*Connection{conn}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package neuron
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestConcatSkipConnection(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	hidden1 := NewLayer("hidden1", conf, sess, 3)
+	hidden2 := NewLayer("hidden2", conf, sess, 3)
+	output, err := NewOutputLayer("output", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(input.Layer, hidden1); err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden1, hidden2); err != nil {
+		panic(err)
+	}
+	if _, err := output.Layer.Concat(hidden2); err != nil {
+		t.Fatal(err)
+	}
+	// hidden1 is one layer closer to the input, so it is delayed by one relay layer
+	relays, err := output.Layer.Concat(hidden1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relays) != 1 || len(relays[0].Neurons) != 3 {
+		t.Fatalf("expected one relay layer of 3 neurons, got %v", relays)
+	}
+	if got := len(output.Layer.Neurons[0].Inputs); got != 6 {
+		t.Errorf("expected the output neuron to have 6 inputs, got %d", got)
+	}
+	if d := output.Layer.Depth(); d != 3 {
+		t.Errorf("expected the output layer at depth 3, got %d", d)
+	}
+	if _, err := hidden2.Concat(hidden2); err == nil {
+		t.Errorf("expected a layer at depth 1 to refuse inputs at depth 2")
+	}
+
+	net := &Network{Sess: sess, Input: input, Layers: append([]*Layer{hidden1, hidden2}, relays...), Output: output}
+	net.On()
+	defer shutdown(t, sess)
+	trainer := NewTrainer(sess, input, output)
+	dataset := []Sample{{X: []float64{0.1, 0.2}, Y: []float64{0.3}}, {X: []float64{0.4, -0.2}, Y: []float64{0.2}}}
+	if _, err := trainer.Fit(dataset, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range relays[0].Neurons {
+		if n.bias != 0 || n.Inputs[0].Weight != 1 {
+			t.Errorf("relay neuron was trained, got bias %.05f weight %.05f", n.bias, n.Inputs[0].Weight)
+		}
+	}
+}
+
+func TestResidual(t *testing.T) {
+	conf := &Config{
+		Precision:       0.0001,
+		Activator:       &Identity{},
+		PreProcessor:    &SumPreProcessor{},
+		Initializer:     &Constant{Value: 1},
+		BiasInitializer: &Zeros{},
+	}
+	zero := &Config{
+		Precision:       0.0001,
+		Activator:       &Identity{},
+		PreProcessor:    &SumPreProcessor{},
+		Initializer:     &Zeros{},
+		BiasInitializer: &Zeros{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	f := NewLayer("f", conf, sess, 2)
+	if err := ConnectLayers(input.Layer, f); err != nil {
+		panic(err)
+	}
+	// f(x) is zero, so the output is x
+	output, err := NewOutputLayer("output", zero, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(f, output.Layer); err != nil {
+		panic(err)
+	}
+	relays, err := output.Layer.Residual(input.Layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relays) != 1 {
+		t.Fatalf("expected one relay layer, got %d", len(relays))
+	}
+	if _, err := f.Residual(output.Layer); err == nil {
+		t.Errorf("expected a residual with a different number of neurons to be refused")
+	}
+
+	net := &Network{Sess: sess, Input: input, Layers: append([]*Layer{f}, relays...), Output: output}
+	net.On()
+	defer shutdown(t, sess)
+	x := []float64{0.5, -1.5, 2}
+	got, err := NewTrainer(sess, input, output).Predict(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, x) {
+		t.Errorf("got %v want %v", got, x)
+	}
+}
+
+func TestGraphSkipAndResidual(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	net, err := NewGraph(sess).
+		Input("input", conf, 2).
+		Layer("hidden1", conf, 3).
+		Layer("hidden2", conf, 3).
+		Output("output", conf, 1).
+		Connect("input", "hidden1").
+		Connect("hidden1", "hidden2").
+		Residual("input", "hidden1").
+		Connect("hidden1", "output").
+		Connect("hidden2", "output").
+		Build()
+	if err == nil {
+		t.Fatalf("expected a residual with a different number of neurons to be refused")
+	}
+
+	net, err = NewGraph(sess).
+		Input("input", conf, 3).
+		Layer("hidden1", conf, 3).
+		Layer("hidden2", conf, 3).
+		Output("output", conf, 1).
+		Connect("input", "hidden1").
+		Connect("hidden1", "hidden2").
+		Residual("input", "hidden2").
+		Connect("hidden2", "output").
+		Connect("hidden1", "output").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one relay delays the input for the residual, another delays hidden1 for the output
+	if len(net.Layers) != 4 {
+		t.Fatalf("expected 2 hidden and 2 relay layers, got %d layers", len(net.Layers))
+	}
+	net.On()
+	trainer := NewTrainer(sess, net.Input, net.Output)
+	dataset := []Sample{{X: []float64{0.1, 0.2, 0.3}, Y: []float64{0.3}}, {X: []float64{0.4, -0.2, 0}, Y: []float64{0.2}}}
+	if _, err := trainer.Fit(dataset, 3); err != nil {
+		t.Fatal(err)
+	}
+	want, err := trainer.Evaluate(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Save(&buf, FORMAT_JSON, net); err != nil {
+		t.Fatal(err)
+	}
+	shutdown(t, sess)
+	loaded, err := Load(&buf, FORMAT_JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Layers[2].Config.Frozen {
+		t.Errorf("relay layer %s was not restored frozen", loaded.Layers[2].Name)
+	}
+	loaded.On()
+	defer shutdown(t, loaded.Sess)
+	got, err := NewTrainer(loaded.Sess, loaded.Input, loaded.Output).Evaluate(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("loaded network has loss %.06f want %.06f", got, want)
+	}
+}
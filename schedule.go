@@ -0,0 +1,169 @@
+package neuron
+
+import "math"
+
+type ScheduleUnit string
+
+const (
+	SCHEDULE_EPOCH = ScheduleUnit("epoch") // the scheduler is consulted at the end of each epoch
+	SCHEDULE_STEP  = ScheduleUnit("step")  // the scheduler is consulted after each training step
+)
+
+// Scheduler gives the learning rate to use at epoch or step t, counting from 0
+type Scheduler interface {
+	LearningRate(t int) float64
+}
+
+// LossObserver is implemented by schedulers that react to the validation loss
+// the session passes the loss along at the end of every epoch
+type LossObserver interface {
+	ObserveLoss(loss float64)
+}
+
+// StepDecay multiplies the learning rate by Factor every StepSize epochs or steps
+type StepDecay struct {
+	Initial  float64
+	Factor   float64
+	StepSize int
+}
+
+func (s *StepDecay) LearningRate(t int) float64 {
+	stepSize := s.StepSize
+	if stepSize < 1 {
+		stepSize = 1
+	}
+	return s.Initial * math.Pow(s.Factor, float64(t/stepSize))
+}
+
+// ExponentialDecay multiplies the learning rate by Rate every epoch or step
+type ExponentialDecay struct {
+	Initial float64
+	Rate    float64
+}
+
+func (s *ExponentialDecay) LearningRate(t int) float64 {
+	return s.Initial * math.Pow(s.Rate, float64(t))
+}
+
+// CosineAnnealing follows half a cosine from Max down to Min over Period epochs or steps and then restarts at Max
+// each period is Mult times longer than the last, Mult is 1 if zero
+type CosineAnnealing struct {
+	Max    float64
+	Min    float64
+	Period int
+	Mult   int
+}
+
+func (s *CosineAnnealing) LearningRate(t int) float64 {
+	period := s.Period
+	if period < 1 {
+		period = 1
+	}
+	mult := s.Mult
+	if mult < 1 {
+		mult = 1
+	}
+	// find the position in the current period
+	for t >= period {
+		t -= period
+		period *= mult
+	}
+	return s.Min + 0.5*(s.Max-s.Min)*(1+math.Cos(math.Pi*float64(t)/float64(period)))
+}
+
+// LinearWarmup increases the learning rate linearly from From to To over Steps epochs or steps and then holds To
+type LinearWarmup struct {
+	From  float64
+	To    float64
+	Steps int
+}
+
+func (s *LinearWarmup) LearningRate(t int) float64 {
+	if t >= s.Steps {
+		return s.To
+	}
+	return s.From + (s.To-s.From)*float64(t)/float64(s.Steps)
+}
+
+// OneCycle raises the learning rate from Min to Max over the first half of Steps and lowers it back over the second
+// after Steps it stays at Min
+type OneCycle struct {
+	Min   float64
+	Max   float64
+	Steps int
+}
+
+func (s *OneCycle) LearningRate(t int) float64 {
+	steps := s.Steps
+	if steps < 1 {
+		steps = 1
+	}
+	return OneCycleLearningRate(s.Min, s.Max, math.Min(float64(t)/float64(steps), 1))
+}
+
+// ReduceOnPlateau multiplies the learning rate by Factor once the validation loss
+// has not improved by more than Threshold for Patience epochs
+// the learning rate never goes below Min
+type ReduceOnPlateau struct {
+	Initial   float64
+	Factor    float64
+	Patience  int
+	Threshold float64
+	Min       float64
+	lr        float64
+	best      float64
+	bad       int // number of epochs without improvement
+	started   bool
+}
+
+func (s *ReduceOnPlateau) LearningRate(t int) float64 {
+	if !s.started {
+		return s.Initial
+	}
+	return s.lr
+}
+
+func (s *ReduceOnPlateau) ObserveLoss(loss float64) {
+	if !s.started {
+		s.started = true
+		s.lr = s.Initial
+		s.best = loss
+		return
+	}
+	if loss < s.best-s.Threshold {
+		s.best = loss
+		s.bad = 0
+		return
+	}
+	s.bad++
+	if s.bad > s.Patience {
+		s.lr = math.Max(s.lr*s.Factor, s.Min)
+		s.bad = 0
+	}
+}
+
+// Sequence runs Schedulers one after another, switching to Schedulers[i+1] at epoch or step Milestones[i]
+// each scheduler counts t from the milestone it started at
+type Sequence struct {
+	Schedulers []Scheduler
+	Milestones []int
+}
+
+func (s *Sequence) LearningRate(t int) float64 {
+	start := 0
+	for i, sched := range s.Schedulers {
+		if i == len(s.Schedulers)-1 || i >= len(s.Milestones) || t < s.Milestones[i] {
+			return sched.LearningRate(t - start)
+		}
+		start = s.Milestones[i]
+	}
+	return 0
+}
+
+func (s *Sequence) ObserveLoss(loss float64) {
+	for _, sched := range s.Schedulers {
+		if o, ok := sched.(LossObserver); ok {
+			o.ObserveLoss(loss)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSchedulers(t *testing.T) {
+	warmupCosine := &Sequence{
+		Schedulers: []Scheduler{
+			&LinearWarmup{From: 0, To: 0.1, Steps: 10},
+			&CosineAnnealing{Max: 0.1, Min: 0.001, Period: 20, Mult: 2},
+		},
+		Milestones: []int{10},
+	}
+
+	tests := []struct {
+		name  string
+		sched Scheduler
+		t     int
+		want  float64
+	}{
+		{"step decay", &StepDecay{Initial: 0.1, Factor: 0.5, StepSize: 10}, 25, 0.025},
+		{"exponential decay", &ExponentialDecay{Initial: 0.1, Rate: 0.9}, 2, 0.081},
+		{"warmup start", warmupCosine, 0, 0},
+		{"warmup middle", warmupCosine, 5, 0.05},
+		{"cosine start", warmupCosine, 10, 0.1},
+		{"cosine middle", warmupCosine, 20, 0.0505},
+		{"cosine restart", warmupCosine, 30, 0.1},
+		{"cosine longer period", warmupCosine, 50, 0.0505},
+		{"one cycle rising", &OneCycle{Min: 0.001, Max: 0.1, Steps: 100}, 25, 0.02575},
+		{"one cycle finished", &OneCycle{Min: 0.001, Max: 0.1, Steps: 100}, 150, 0.001},
+		{"one cycle without steps", &OneCycle{Min: 0.001, Max: 0.1}, 0, 0.001},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sched.LearningRate(tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %.05f want %.05f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReduceOnPlateau(t *testing.T) {
+	sess := NewSession(0.1).SetScheduler(&ReduceOnPlateau{
+		Initial:  0.1,
+		Factor:   0.5,
+		Patience: 1,
+		Min:      0.03,
+	}, SCHEDULE_EPOCH)
+
+	for i, loss := range []float64{5, 4, 4, 4, 4, 4, 4} {
+		sess.NextEpoch(loss)
+		t.Logf("epoch %d loss %.1f lr %.4f", i+1, loss, sess.LearningRate())
+	}
+	if lr := sess.LearningRate(); lr != 0.03 {
+		t.Errorf("got learning rate %.4f want 0.03", lr)
+	}
+}
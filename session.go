@@ -2,6 +2,9 @@ package neuron
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 )
@@ -15,20 +18,39 @@ const (
 	MODE_OFF        = Mode("")
 )
 
-type SessionCache struct {
-	loss        map[time.Time]float64
-	predictions map[time.Time]float64
-	accuracy    map[time.Time]float64
-	// todo: add more metrics
+func (m Mode) Predicting() bool {
+	return m == MODE_PREDICTING || m == MODE_DUAL
+}
+
+func (m Mode) Training() bool {
+	return m == MODE_TRAINING || m == MODE_DUAL
 }
 
 type Session struct {
 	ctx          context.Context
 	Stop         context.CancelFunc
 	neuronIDcur  uint64
+	sampleIDcur  uint64
 	mode         Mode
 	loss         float64
 	learningRate float64
+	scheduler    Scheduler
+	scheduleUnit ScheduleUnit
+	epoch        int
+	step         int
+	epochStart   time.Time // when the current epoch started
+	epochSteps   int       // the step the current epoch started at
+	cache        *SessionCache
+	running      map[NeuronID]int // number of running loops of each neuron
+	idle         chan struct{}    // closed once no neuron is running
+	pending      int              // number of cached forward passes still waiting for their backward pass
+	settled      chan struct{}    // closed once nothing is pending
+	seed         int64
+	rng          *rand.Rand // used when building the network, safe to share between goroutines
+	batchSize    int
+	cacheSize    int
+	neurons      []*Neuron // every neuron created in the session
+	backInTime   bool      // gradients flow back through delayed connections
 	mu           sync.RWMutex
 }
 
@@ -51,16 +73,94 @@ func (s *Session) LearningRate() float64 {
 	return s.learningRate
 }
 
+// SetScheduler makes the session set the learning rate from sched at every epoch or step
+// the learning rate is set right away for the current epoch or step
+func (s *Session) SetScheduler(sched Scheduler, unit ScheduleUnit) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scheduler = sched
+	s.scheduleUnit = unit
+	s.schedule(unit)
+	return s
+}
+
+// NextStep marks the end of a training step, loss is the loss of the step
+func (s *Session) NextStep(loss float64) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Add(METRIC_STEP_LOSS, s.epoch, s.step, loss)
+	s.step++
+	s.schedule(SCHEDULE_STEP)
+	return s
+}
+
+// NextEpoch marks the end of an epoch, loss is the validation loss of the epoch
+func (s *Session) NextEpoch(loss float64) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Add(METRIC_LOSS, s.epoch, s.step, loss)
+	s.cache.Add(METRIC_LEARNING_RATE, s.epoch, s.step, s.learningRate)
+	if elapsed := time.Since(s.epochStart).Seconds(); elapsed > 0 && s.step > s.epochSteps {
+		s.cache.Add(METRIC_THROUGHPUT, s.epoch, s.step, float64(s.step-s.epochSteps)/elapsed)
+	}
+	s.epoch++
+	s.epochStart = time.Now()
+	s.epochSteps = s.step
+	s.loss = loss
+	if o, ok := s.scheduler.(LossObserver); ok {
+		o.ObserveLoss(loss)
+	}
+	s.schedule(SCHEDULE_EPOCH)
+	return s
+}
+
+// Record adds a custom metric to the session's history
+func (s *Session) Record(name string, value float64) *Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.cache.Add(name, s.epoch, s.step, value)
+	return s
+}
+
+// Metrics is the history of the metrics recorded by the session
+func (s *Session) Metrics() *SessionCache {
+	return s.cache
+}
+
+// schedule updates the learning rate if the scheduler runs on unit
+func (s *Session) schedule(unit ScheduleUnit) {
+	if s.scheduler == nil || s.scheduleUnit != unit {
+		return
+	}
+	t := s.epoch
+	if unit == SCHEDULE_STEP {
+		t = s.step
+	}
+	s.learningRate = s.scheduler.LearningRate(t)
+}
+
+func (s *Session) Epoch() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.epoch
+}
+
+func (s *Session) Step() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.step
+}
+
 func (s *Session) Predicting() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.mode == MODE_PREDICTING || s.mode == MODE_DUAL
+	return s.mode.Predicting()
 }
 
 func (s *Session) Training() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.mode == MODE_TRAINING || s.mode == MODE_DUAL
+	return s.mode.Training()
 }
 
 func (s *Session) SetMode(m Mode) *Session {
@@ -100,14 +200,290 @@ func (s *Session) NextIDs(cnt int) (ids []NeuronID) {
 	return ids
 }
 
+// NextSampleIDs reserves cnt sample IDs, the IDs are consecutive
+func (s *Session) NextSampleIDs(cnt int) (ids []uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids = make([]uint64, cnt)
+	for i := 0; i < cnt; i++ {
+		s.sampleIDcur += 1
+		ids[i] = s.sampleIDcur
+	}
+	return ids
+}
+
+// register marks a loop of neuron id as running
+func (s *Session) register(id NeuronID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.running) == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.running[id]++
+}
+
+// unregister marks a loop of neuron id as stopped
+func (s *Session) unregister(id NeuronID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[id]--
+	if s.running[id] <= 0 {
+		delete(s.running, id)
+	}
+	if len(s.running) == 0 {
+		close(s.idle)
+	}
+}
+
+// addPending counts the forward passes that still wait for their backward pass
+func (s *Session) addPending(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == 0 && delta > 0 {
+		s.settled = make(chan struct{})
+	}
+	s.pending += delta
+	if s.pending == 0 && delta < 0 {
+		close(s.settled)
+	}
+}
+
+// Settle blocks until every forward pass made while training has gone through its backward pass
+// and every neuron has updated its weights and bias
+func (s *Session) Settle() error {
+	s.mu.RLock()
+	settled := s.settled
+	s.mu.RUnlock()
+	select {
+	case <-settled:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Running returns the IDs of the neurons that have not stopped yet
+func (s *Session) Running() []NeuronID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]NeuronID, 0, len(s.running))
+	for id := range s.running {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Wait blocks until every neuron of the session has stopped
+func (s *Session) Wait() {
+	s.mu.RLock()
+	idle := s.idle
+	s.mu.RUnlock()
+	<-idle
+}
+
+// Shutdown stops the session and waits for every neuron to stop
+// if ctx is done first it returns an error that lists the neurons that are still running
+func (s *Session) Shutdown(ctx context.Context) error {
+	s.Stop()
+	s.mu.RLock()
+	idle := s.idle
+	s.mu.RUnlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		ids := s.Running()
+		return fmt.Errorf("%d neurons did not stop: %v", len(ids), ids)
+	}
+}
+
+// SetSeed seeds the session's random numbers, two sessions with the same seed build and train identical networks
+// it must be called before the network is built
+func (s *Session) SetSeed(seed int64) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seed = seed
+	s.rng.Seed(seed)
+	return s
+}
+
+func (s *Session) Seed() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seed
+}
+
+// Rand returns the session's random number generator
+func (s *Session) Rand() *rand.Rand {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rng
+}
+
+// NewRand creates a random number generator for a single neuron
+// it is derived from the session's seed and the neuron's ID so it does not depend on the order neurons use it in
+func (s *Session) NewRand(id NeuronID) *rand.Rand {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return rand.New(rand.NewSource(s.seed ^ int64(id)*0x5851f42d4c957f2d))
+}
+
+// SetBatchSize makes every neuron update its weights and bias with the mean gradient of size samples
+func (s *Session) SetBatchSize(size int) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size < 1 {
+		size = 1
+	}
+	s.batchSize = size
+	return s
+}
+
+func (s *Session) BatchSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.batchSize
+}
+
+// SetCacheSize limits the number of forward passes each neuron keeps for their backward pass, 0 for no limit
+// when the limit is reached new forward passes are not cached and their backward pass gets no gradient, the cached ones are kept
+// it must be called before the network is built
+func (s *Session) SetCacheSize(size int) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cacheSize = size
+	return s
+}
+
+func (s *Session) CacheSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cacheSize
+}
+
+// add keeps track of a neuron created in the session
+func (s *Session) add(n *Neuron) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.neurons = append(s.neurons, n)
+}
+
+// Flush waits for every pending backward pass and updates every neuron with the gradients of its unfinished batch
+func (s *Session) Flush() error {
+	if err := s.Settle(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	neurons := s.neurons
+	s.mu.RUnlock()
+	for _, n := range neurons {
+		n.applyGradients()
+	}
+	return nil
+}
+
+// forget drops the forward passes still waiting for a backward pass, so training does not wait for them
+// the network must be idle, they are left over from forward passes made in training mode that were never trained on
+func (s *Session) forget() {
+	s.mu.Lock()
+	neurons := s.neurons
+	if s.pending > 0 {
+		s.pending = 0
+		close(s.settled)
+	}
+	s.mu.Unlock()
+	for _, n := range neurons {
+		n.cache.Clear()
+	}
+}
+
+// ResetState makes every delayed connection deliver its initial value in the next time step
+// it waits for the last time step to reach every delayed connection first
+func (s *Session) ResetState() error {
+	for _, conn := range s.delayed() {
+		initial := conn.Initial
+		if !conn.swapState(s.ctx.Done(), func(float64) float64 { return initial }) {
+			return s.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// delayed returns the delayed connections between the neurons of the session
+func (s *Session) delayed() []*Connection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var conns []*Connection
+	for _, n := range s.neurons {
+		for _, conn := range n.Inputs {
+			if conn.Delayed {
+				conns = append(conns, conn)
+			}
+		}
+	}
+	return conns
+}
+
+func (s *Session) throughTime() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backInTime
+}
+
+// beginThroughTime makes the following backward passes go back through time, from the last time step to the first
+// every delayed connection starts with a gradient of zero, so nothing flows back from after the last time step
+// it returns the context's error if the session stops
+func (s *Session) beginThroughTime() error {
+	s.mu.Lock()
+	s.backInTime = true
+	s.mu.Unlock()
+	for _, conn := range s.delayed() {
+		select {
+		case conn.Backward <- &Packet{NeuronID: conn.ConsumingNeuron}:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// endThroughTime waits for the gradients of the first time step and throws them away,
+// they belong to the initial values of the delayed connections
+func (s *Session) endThroughTime() error {
+	for _, conn := range s.delayed() {
+		select {
+		case <-conn.Backward:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backInTime = false
+	return nil
+}
+
 func NewSession(lr float64) *Session {
 	ctx, stop := context.WithCancel(context.Background())
+	idle := make(chan struct{})
+	close(idle)
+	seed := time.Now().UnixNano()
 	return &Session{
 		ctx:          ctx,
 		Stop:         stop,
 		mode:         MODE_OFF,
 		mu:           sync.RWMutex{},
 		learningRate: lr,
+		epochStart:   time.Now(),
+		cache:        NewSessionCache(),
+		running:      map[NeuronID]int{},
+		idle:         idle,
+		settled:      idle,
+		seed:         seed,
+		rng:          newLockedRand(seed),
+		batchSize:    1,
+		cacheSize:    DEFAULT_CACHE_SIZE,
 	}
 }
 
@@ -0,0 +1,177 @@
+package neuron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shutdown stops every neuron of sess and fails the test if any of them hang
+func shutdown(t *testing.T, sess *Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sess.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShutdownIdleNetwork(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &LeakyRelu{0.5},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 3)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	for _, l := range []*Layer{input.Layer, hidden, output.Layer} {
+		l.On()
+	}
+	if running := sess.Running(); len(running) != 6 {
+		t.Errorf("expected 6 running neurons, got %v", running)
+	}
+
+	// every neuron is blocked waiting for input
+	shutdown(t, sess)
+	if running := sess.Running(); len(running) != 0 {
+		t.Errorf("expected no running neurons, got %v", running)
+	}
+	sess.Wait()
+
+	// nothing takes the gradients any more, so sending them fails instead of blocking
+	err = nil
+	for i := 0; i < 3 && err == nil; i++ {
+		err = output.Backward(&Packet{})
+	}
+	if err != context.Canceled {
+		t.Errorf("expected the gradient to be refused once the session stopped, got %v", err)
+	}
+}
+
+func TestSessionMetrics(t *testing.T) {
+	sess := NewSession(0.1).SetScheduler(&StepDecay{Initial: 0.1, Factor: 0.5, StepSize: 1}, SCHEDULE_EPOCH)
+
+	for epoch := 0; epoch < 3; epoch++ {
+		for step := 0; step < 4; step++ {
+			sess.NextStep(float64(10 - epoch))
+		}
+		sess.Record("accuracy", float64(epoch)/10)
+		sess.NextEpoch(float64(3 - epoch))
+	}
+
+	metrics := sess.Metrics()
+	if got := metrics.Values(METRIC_LOSS); len(got) != 3 || got[0] != 3 || got[2] != 1 {
+		t.Errorf("unexpected loss history %v", got)
+	}
+	if got := metrics.Values(METRIC_LEARNING_RATE); len(got) != 3 || got[1] != 0.05 {
+		t.Errorf("unexpected learning rate history %v", got)
+	}
+	if got := metrics.Series(METRIC_STEP_LOSS); len(got) != 12 || got[11].Epoch != 2 || got[11].Step != 11 {
+		t.Errorf("unexpected step loss history %v", got)
+	}
+	if got := metrics.Values("accuracy"); len(got) != 3 {
+		t.Errorf("unexpected accuracy history %v", got)
+	}
+
+	var csvOut bytes.Buffer
+	if err := metrics.WriteCSV(&csvOut); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if lines[0] != "metric,epoch,step,time,value" {
+		t.Errorf("unexpected csv header %q", lines[0])
+	}
+	// header, accuracy, learning rate, loss, step loss and (when the clock moved) throughput
+	if len(lines) < 1+3+3+3+12 {
+		t.Errorf("expected at least 22 csv lines, got %d", len(lines))
+	}
+
+	var jsonOut bytes.Buffer
+	if err := metrics.WriteJSON(&jsonOut); err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string][]Metric{}
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded[METRIC_LOSS]) != 3 || decoded[METRIC_LOSS][1].Value != 2 {
+		t.Errorf("unexpected json loss history %v", decoded[METRIC_LOSS])
+	}
+}
+
+func TestSeededSessionsAreReproducible(t *testing.T) {
+	train := func(seed int64) []float64 {
+		conf := &Config{
+			Precision:    0.0001,
+			Activator:    &Tanh{},
+			PreProcessor: &SumPreProcessor{},
+			RandomFactor: 0.01,
+		}
+		sess := NewSession(0.01).SetSeed(seed)
+		input, err := NewInputLayer("input", conf, sess, 2)
+		if err != nil {
+			panic(err)
+		}
+		hidden := NewLayer("hidden", conf, sess, 5)
+		if err := ConnectLayers(input.Layer, hidden); err != nil {
+			panic(err)
+		}
+		output, err := NewOutputLayer("output", conf, sess, 1)
+		if err != nil {
+			panic(err)
+		}
+		if err := ConnectLayers(hidden, output.Layer); err != nil {
+			panic(err)
+		}
+		net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+		net.On()
+		defer shutdown(t, sess)
+
+		dataset := make([]Sample, 30)
+		for i := range dataset {
+			x1, x2 := float64(i%5)/5, float64(i%7)/7
+			dataset[i] = Sample{X: []float64{x1, x2}, Y: []float64{x1 * x2}}
+		}
+		if _, err := NewTrainer(sess, input, output).Fit(dataset, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		var params []float64
+		for _, l := range []*Layer{input.Layer, hidden, output.Layer} {
+			for _, n := range l.Neurons {
+				n.mu.Lock()
+				params = append(params, n.bias)
+				for _, conn := range n.Inputs {
+					params = append(params, conn.Weight)
+				}
+				n.mu.Unlock()
+			}
+		}
+		return params
+	}
+
+	a, b, c := train(42), train(42), train(7)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("two sessions with the same seed trained different networks")
+	}
+	if reflect.DeepEqual(a, c) {
+		t.Errorf("two sessions with different seeds trained the same network")
+	}
+}
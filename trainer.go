@@ -0,0 +1,384 @@
+package neuron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Sample is a single input and the output it should produce
+type Sample struct {
+	X []float64
+	Y []float64
+}
+
+// Trainer drives a network from its input layer to its output layer
+// it sends one sample through the network at a time, so it is safe to use from several goroutines
+type Trainer struct {
+	Sess       *Session
+	Input      *InputLayer
+	Output     *OutputLayer
+	Validation []Sample // if set, Fit reports the loss on these samples at the end of each epoch
+	Depth      int      // number of samples PredictAll keeps in flight, 1 if 0
+	Truncate   int      // number of time steps FitSequences goes back through at once, the whole sequence if 0
+	mu         sync.Mutex
+}
+
+func NewTrainer(sess *Session, input *InputLayer, output *OutputLayer) *Trainer {
+	return &Trainer{
+		Sess:   sess,
+		Input:  input,
+		Output: output,
+	}
+}
+
+// Fit trains the network on dataset for the number of epochs and returns the loss of the last epoch
+func (t *Trainer) Fit(dataset []Sample, epochs int) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(dataset) == 0 {
+		return 0, fmt.Errorf("dataset is empty")
+	}
+	if err := t.check(dataset, true); err != nil {
+		return 0, err
+	}
+	if err := t.check(t.Validation, true); err != nil {
+		return 0, err
+	}
+
+	t.Sess.forget()
+	var loss float64
+	for i := 0; i < epochs; i++ {
+		t.Sess.SetMode(MODE_TRAINING)
+		var lossSum float64
+		for _, s := range dataset {
+			outputs, err := t.forward(context.Background(), s.X, 0)
+			if err != nil {
+				return 0, err
+			}
+			l, err := t.Output.BackwardCost(outputs, s.Y)
+			if err != nil {
+				return 0, err
+			}
+			// wait for every update before the next sample so training is reproducible
+			if err := t.Sess.Settle(); err != nil {
+				return 0, err
+			}
+			lossSum += l
+			t.Sess.NextStep(l)
+		}
+		// the last batch of the epoch may be smaller than the batch size
+		if err := t.Sess.Flush(); err != nil {
+			return 0, err
+		}
+		loss = lossSum / float64(len(dataset))
+
+		if len(t.Validation) > 0 {
+			t.Sess.Record(METRIC_TRAIN_LOSS, loss)
+			var err error
+			if loss, err = t.evaluate(t.Validation); err != nil {
+				return 0, err
+			}
+		}
+		t.Sess.NextEpoch(loss)
+	}
+	return loss, nil
+}
+
+// Evaluate returns the mean loss of the network on dataset without training it
+func (t *Trainer) Evaluate(dataset []Sample) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(dataset) == 0 {
+		return 0, fmt.Errorf("dataset is empty")
+	}
+	if err := t.check(dataset, true); err != nil {
+		return 0, err
+	}
+	return t.evaluate(dataset)
+}
+
+// Predict returns the outputs of the network for x
+func (t *Trainer) Predict(x []float64) ([]float64, error) {
+	return t.PredictContext(context.Background(), x)
+}
+
+// PredictContext returns the outputs of the network for x, or ctx.Err() if ctx is done first
+// the network is left ready for the next sample either way
+func (t *Trainer) PredictContext(ctx context.Context, x []float64) ([]float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.check([]Sample{{X: x}}, false); err != nil {
+		return nil, err
+	}
+	t.Sess.SetMode(MODE_PREDICTING)
+	outputs, err := t.forward(ctx, x, t.Sess.NextSampleIDs(1)[0])
+	if err != nil {
+		return nil, err
+	}
+	ys := make([]float64, len(outputs))
+	for i, p := range outputs {
+		ys[i] = p.X
+	}
+	return ys, nil
+}
+
+// PredictAll returns the outputs of the network for every x in order
+// up to Depth samples are in the network at once, so each layer can work on a different sample
+func (t *Trainer) PredictAll(xs [][]float64) ([][]float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dataset := make([]Sample, len(xs))
+	for i, x := range xs {
+		dataset[i].X = x
+	}
+	if err := t.check(dataset, false); err != nil {
+		return nil, err
+	}
+	if len(xs) == 0 {
+		return [][]float64{}, nil
+	}
+	t.Sess.SetMode(MODE_PREDICTING)
+
+	ids := t.Sess.NextSampleIDs(len(xs))
+	depth := t.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	inFlight := make(chan struct{}, depth)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i, x := range xs {
+			select {
+			case inFlight <- struct{}{}:
+			case <-stop:
+				return
+			}
+			if t.send(context.Background(), x, ids[i]) != nil {
+				return
+			}
+		}
+	}()
+
+	ys := make([][]float64, len(xs))
+	for range xs {
+		outputs, err := t.Output.ForwardContext(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		<-inFlight
+		id := outputs[0].SampleID
+		for _, p := range outputs {
+			if p.SampleID != id {
+				return nil, fmt.Errorf("outputs of samples %d and %d were mixed up", id, p.SampleID)
+			}
+		}
+		i := int(id - ids[0])
+		if id < ids[0] || i >= len(ys) || ys[i] != nil {
+			return nil, fmt.Errorf("unexpected output for sample %d", id)
+		}
+		ys[i] = make([]float64, len(outputs))
+		for j, p := range outputs {
+			ys[i][j] = p.X
+		}
+	}
+	return ys, nil
+}
+
+// RunSequence sends every x through the network one time step after the other and returns the outputs of each step
+// the state of the delayed connections carries on from the previous call, see Session.ResetState
+func (t *Trainer) RunSequence(xs [][]float64) ([][]float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dataset := make([]Sample, len(xs))
+	for i, x := range xs {
+		dataset[i].X = x
+	}
+	if err := t.check(dataset, false); err != nil {
+		return nil, err
+	}
+	t.Sess.SetMode(MODE_PREDICTING)
+	ys := make([][]float64, len(xs))
+	for i, x := range xs {
+		outputs, err := t.forward(context.Background(), x, 0)
+		if err != nil {
+			return nil, err
+		}
+		ys[i] = make([]float64, len(outputs))
+		for j, p := range outputs {
+			ys[i][j] = p.X
+		}
+	}
+	return ys, nil
+}
+
+// FitSequences trains the network on every sequence for the number of epochs
+// and returns the mean loss of a time step in the last epoch
+// the state is reset before each sequence, and the gradients go back through Truncate time steps at a time
+func (t *Trainer) FitSequences(sequences [][]Sample, epochs int) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(sequences) == 0 {
+		return 0, fmt.Errorf("no sequences")
+	}
+	for i, seq := range sequences {
+		if len(seq) == 0 {
+			return 0, fmt.Errorf("sequence %d is empty", i)
+		}
+		if err := t.check(seq, true); err != nil {
+			return 0, fmt.Errorf("sequence %d: %v", i, err)
+		}
+		if window := t.window(seq); t.Sess.CacheSize() > 0 && window > t.Sess.CacheSize() {
+			return 0, fmt.Errorf("sequence %d goes back through %d time steps, the neurons only cache %d", i, window, t.Sess.CacheSize())
+		}
+	}
+
+	t.Sess.forget()
+	var loss float64
+	for i := 0; i < epochs; i++ {
+		t.Sess.SetMode(MODE_TRAINING)
+		var (
+			lossSum float64
+			steps   int
+		)
+		for _, seq := range sequences {
+			if err := t.Sess.ResetState(); err != nil {
+				return 0, err
+			}
+			window := t.window(seq)
+			for start := 0; start < len(seq); start += window {
+				end := start + window
+				if end > len(seq) {
+					end = len(seq)
+				}
+				l, err := t.throughTime(seq[start:end])
+				if err != nil {
+					return 0, err
+				}
+				lossSum += l
+				steps += end - start
+				t.Sess.NextStep(l / float64(end-start))
+			}
+		}
+		if err := t.Sess.Flush(); err != nil {
+			return 0, err
+		}
+		loss = lossSum / float64(steps)
+		t.Sess.NextEpoch(loss)
+	}
+	return loss, nil
+}
+
+// throughTime sends every sample of seq through the network, then sends the gradients back from the last one to the first
+// it returns the summed loss of the time steps
+func (t *Trainer) throughTime(seq []Sample) (float64, error) {
+	outputs := make([][]*Packet, len(seq))
+	for i, s := range seq {
+		var err error
+		if outputs[i], err = t.forward(context.Background(), s.X, 0); err != nil {
+			return 0, err
+		}
+	}
+	if err := t.Sess.beginThroughTime(); err != nil {
+		return 0, err
+	}
+	var loss float64
+	for i := len(seq) - 1; i >= 0; i-- {
+		l, err := t.Output.BackwardCost(outputs[i], seq[i].Y)
+		if err != nil {
+			return 0, err
+		}
+		loss += l
+	}
+	if err := t.Sess.Settle(); err != nil {
+		return 0, err
+	}
+	return loss, t.Sess.endThroughTime()
+}
+
+// window is the number of time steps of seq that go back through time at once
+func (t *Trainer) window(seq []Sample) int {
+	if t.Truncate > 0 && t.Truncate < len(seq) {
+		return t.Truncate
+	}
+	return len(seq)
+}
+
+// PredictClass returns the index of the largest output of the network for x
+func (t *Trainer) PredictClass(x []float64) (int, error) {
+	ys, err := t.Predict(x)
+	if err != nil {
+		return 0, err
+	}
+	return ArgMax(ys), nil
+}
+
+// PredictTopK returns the indices of the k largest outputs of the network for x, largest first
+func (t *Trainer) PredictTopK(x []float64, k int) ([]int, error) {
+	ys, err := t.Predict(x)
+	if err != nil {
+		return nil, err
+	}
+	return TopK(ys, k), nil
+}
+
+func (t *Trainer) evaluate(dataset []Sample) (float64, error) {
+	t.Sess.SetMode(MODE_PREDICTING)
+	var lossSum float64
+	for _, s := range dataset {
+		outputs, err := t.forward(context.Background(), s.X, 0)
+		if err != nil {
+			return 0, err
+		}
+		predictions := make([]float64, len(outputs))
+		for i, p := range outputs {
+			predictions[i] = p.X
+		}
+		l, _, err := t.Output.Cost.Cost(predictions, s.Y)
+		if err != nil {
+			return 0, err
+		}
+		lossSum += l
+	}
+	return lossSum / float64(len(dataset)), nil
+}
+
+// forward sends x through the network as sample id and waits for the outputs
+// if ctx is done first the outputs of the sample are thrown away when they arrive
+func (t *Trainer) forward(ctx context.Context, x []float64, id uint64) ([]*Packet, error) {
+	if err := t.send(ctx, x, id); err != nil {
+		t.Output.Discard(id)
+		return nil, err
+	}
+	outputs, err := t.Output.ForwardContext(ctx)
+	if err != nil {
+		t.Output.Discard(id)
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// send sends x into the network as sample id
+func (t *Trainer) send(ctx context.Context, x []float64, id uint64) error {
+	packets := make([]*Packet, len(x))
+	for i, v := range x {
+		packets[i] = &Packet{SampleID: id, X: v}
+	}
+	// the length was checked so this only fails if ctx is done or the session stops
+	return t.Input.ForwardContext(ctx, packets...)
+}
+
+// check makes sure every sample fits the network before anything is sent into it
+// a partially sent sample would leave the network waiting forever
+func (t *Trainer) check(dataset []Sample, targets bool) error {
+	for i, s := range dataset {
+		if len(s.X) != len(t.Input.Inputs) {
+			return fmt.Errorf("sample %d has %d inputs, the network has %d", i, len(s.X), len(t.Input.Inputs))
+		}
+		if targets && len(s.Y) != len(t.Output.Outputs) {
+			return fmt.Errorf("sample %d has %d targets, the network has %d", i, len(s.Y), len(t.Output.Outputs))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package neuron
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrainerRejectsMismatchedSamples(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &LeakyRelu{0.5},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 1)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(input.Layer, output.Layer); err != nil {
+		panic(err)
+	}
+	input.Layer.On()
+	output.Layer.On()
+	trainer := NewTrainer(sess, input, output)
+	defer shutdown(t, sess)
+
+	// the bad sample comes last so a partial send would block the network
+	dataset := []Sample{
+		{X: []float64{1, 2}, Y: []float64{3}},
+		{X: []float64{1}, Y: []float64{3}},
+	}
+	if _, err := trainer.Fit(dataset, 1); err == nil {
+		t.Errorf("expected Fit to reject a sample with too few inputs")
+	}
+	if _, err := trainer.Evaluate([]Sample{{X: []float64{1, 2}}}); err == nil {
+		t.Errorf("expected Evaluate to reject a sample without targets")
+	}
+	if _, err := trainer.Predict([]float64{1, 2, 3}); err == nil {
+		t.Errorf("expected Predict to reject too many inputs")
+	}
+
+	// the network is still usable
+	if _, err := trainer.Fit(dataset[:1], 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trainer.Predict([]float64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiniBatch(t *testing.T) {
+	build := func(batchSize int) (*Network, *Trainer) {
+		conf := &Config{
+			Activator:    &Tanh{},
+			PreProcessor: &SumPreProcessor{},
+		}
+		sess := NewSession(0.01).SetSeed(3).SetBatchSize(batchSize).SetCacheSize(4)
+		input, err := NewInputLayer("input", conf, sess, 2)
+		if err != nil {
+			panic(err)
+		}
+		hidden := NewLayer("hidden", conf, sess, 3)
+		if err := ConnectLayers(input.Layer, hidden); err != nil {
+			panic(err)
+		}
+		output, err := NewOutputLayer("output", conf, sess, 1)
+		if err != nil {
+			panic(err)
+		}
+		if err := ConnectLayers(hidden, output.Layer); err != nil {
+			panic(err)
+		}
+		net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+		net.On()
+		return net, NewTrainer(sess, input, output)
+	}
+	params := func(net *Network) []float64 {
+		var ps []float64
+		for _, l := range []*Layer{net.Input.Layer, net.Layers[0], net.Output.Layer} {
+			for _, n := range l.Neurons {
+				n.mu.Lock()
+				ps = append(ps, n.bias)
+				for _, conn := range n.Inputs {
+					ps = append(ps, conn.Weight)
+				}
+				n.mu.Unlock()
+			}
+		}
+		return ps
+	}
+	sample := Sample{X: []float64{0.3, -0.7}, Y: []float64{0.5}}
+
+	// the mean gradient of a batch of identical samples is the gradient of one sample
+	single, singleTrainer := build(1)
+	defer shutdown(t, single.Sess)
+	batched, batchedTrainer := build(4)
+	defer shutdown(t, batched.Sess)
+	if _, err := singleTrainer.Fit([]Sample{sample}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batchedTrainer.Fit([]Sample{sample, sample, sample, sample}, 1); err != nil {
+		t.Fatal(err)
+	}
+	want, got := params(single), params(batched)
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Fatalf("batched parameter %d is %.015f want %.015f", i, got[i], want[i])
+		}
+	}
+
+	// nothing is updated before the batch is complete
+	before := params(batched)
+	batched.Sess.SetMode(MODE_TRAINING)
+	outputs, err := batchedTrainer.forward(context.Background(), sample.X, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batched.Output.BackwardCost(outputs, sample.Y); err != nil {
+		t.Fatal(err)
+	}
+	if err := batched.Sess.Settle(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(params(batched), before) {
+		t.Errorf("parameters changed before the batch was complete")
+	}
+	if err := batched.Sess.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(params(batched), before) {
+		t.Errorf("flush did not update the parameters")
+	}
+
+	// forward passes without a backward pass do not grow the caches
+	for i := 0; i < 10; i++ {
+		if _, err := batchedTrainer.forward(context.Background(), sample.X, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range batched.Output.Layer.Neurons {
+		if got := len(n.cache.Get()); got != 4 {
+			t.Errorf("expected the cache to be limited to 4 forward passes, got %d", got)
+		}
+	}
+	// the forward passes left without a backward pass do not hold up training
+	if _, err := batchedTrainer.Fit([]Sample{sample}, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range batched.Output.Layer.Neurons {
+		if got := len(n.cache.Get()); got != 0 {
+			t.Errorf("expected the cache to be empty after training, got %d forward passes", got)
+		}
+	}
+}
+
+func TestPredictAll(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 3)
+	if err != nil {
+		panic(err)
+	}
+	layers := []*Layer{NewLayer("hidden1", conf, sess, 4), NewLayer("hidden2", conf, sess, 4)}
+	output, err := NewOutputLayer("output", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	chain := []*Layer{input.Layer, layers[0], layers[1], output.Layer}
+	for i := 1; i < len(chain); i++ {
+		if err := ConnectLayers(chain[i-1], chain[i]); err != nil {
+			panic(err)
+		}
+	}
+	net := &Network{Sess: sess, Input: input, Layers: layers, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+
+	trainer := NewTrainer(sess, input, output)
+	trainer.Depth = 8
+	xs := make([][]float64, 50)
+	for i := range xs {
+		xs[i] = []float64{float64(i) / 50, float64(i%7) / 7, -float64(i%3) / 3}
+	}
+	got, err := trainer.PredictAll(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range xs {
+		want, err := trainer.Predict(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("sample %d: got %v want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestPredictContext(t *testing.T) {
+	conf := &Config{
+		Precision:    0.0001,
+		Activator:    &Tanh{},
+		PreProcessor: &SumPreProcessor{},
+	}
+	sess := NewSession(0.001)
+	input, err := NewInputLayer("input", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	hidden := NewLayer("hidden", conf, sess, 3)
+	if err := ConnectLayers(input.Layer, hidden); err != nil {
+		panic(err)
+	}
+	output, err := NewOutputLayer("output", conf, sess, 2)
+	if err != nil {
+		panic(err)
+	}
+	if err := ConnectLayers(hidden, output.Layer); err != nil {
+		panic(err)
+	}
+	net := &Network{Sess: sess, Input: input, Layers: []*Layer{hidden}, Output: output}
+	net.On()
+	defer shutdown(t, sess)
+
+	trainer := NewTrainer(sess, input, output)
+	x := []float64{0.5, -0.25}
+	want, err := trainer.Predict(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a stuck neuron holds up every sample sent after it, a stuck output neuron leaves the other outputs half read
+	for _, stuck := range []*Neuron{hidden.Neurons[1], output.Layer.Neurons[1]} {
+		stuck.mu.Lock()
+		for i := 0; i < 5; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			_, err := trainer.PredictContext(ctx, []float64{float64(i), float64(i)})
+			cancel()
+			if err != context.DeadlineExceeded {
+				t.Errorf("expected the prediction to time out, got %v", err)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := trainer.PredictContext(ctx, x); err != context.Canceled {
+			t.Errorf("expected the prediction to be canceled, got %v", err)
+		}
+		stuck.mu.Unlock()
+
+		// the abandoned samples do not get mixed up with the next ones
+		for i := 0; i < 3; i++ {
+			got, err := trainer.Predict(x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v want %v after abandoned predictions", got, want)
+			}
+		}
+	}
+
+	// a sample abandoned by a prediction is not trained on when it gets through after training started
+	hidden.Neurons[1].mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	if _, err := trainer.PredictContext(ctx, x); err != context.DeadlineExceeded {
+		t.Errorf("expected the prediction to time out, got %v", err)
+	}
+	cancel()
+	hidden.Neurons[1].mu.Unlock()
+	if _, err := trainer.Fit([]Sample{{X: x, Y: []float64{0.5, 0}}}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trainer.Predict(x); err != nil {
+		t.Fatal(err)
+	}
+
+	// once the session stops there is nothing to read
+	shutdown(t, sess)
+	if got := output.Forward(); len(got) != 0 {
+		t.Errorf("expected no outputs after the session stopped, got %v", got)
+	}
+}